@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/dknathalage/dkn/pkg/config"
+	"github.com/dknathalage/dkn/pkg/output"
 	"github.com/dknathalage/dkn/pkg/plugin"
 	"github.com/dknathalage/dkn/pkg/plugins/terraform"
 	"github.com/dknathalage/dkn/pkg/scanner"
@@ -15,94 +21,166 @@ import (
 
 const version = "0.1.0"
 
-func runPlugin(ctx context.Context, registry *plugin.Registry, fileScanner *scanner.FileScanner, outputDir string, pluginName string) error {
+func runPlugin(ctx context.Context, registry *plugin.Registry, fileScanner *scanner.FileScanner, out *output.Writer, prune bool, pluginName string) error {
 	plugin, exists := registry.Get(pluginName)
 	if !exists {
 		fmt.Printf("❌ Error: Plugin '%s' not found\n\n", pluginName)
 		fmt.Println("Available plugins:")
-		for name := range registry.All() {
+		for _, name := range registry.Names() {
 			fmt.Printf("  - %s\n", name)
 		}
 		return fmt.Errorf("plugin not found: %s", pluginName)
 	}
 
-	// For plugins with patterns, scan for matching configs
-	configPattern := plugin.ConfigFile()
-	if strings.Contains(configPattern, "*") {
-		configFiles, err := fileScanner.ScanForConfigs()
-		if err != nil {
-			return fmt.Errorf("failed to scan for config files: %w", err)
-		}
+	resources, err := fileScanner.LoadConfigs()
+	if err != nil {
+		return fmt.Errorf("failed to load config files: %w", err)
+	}
 
-		var matchingConfigs []string
-		for _, configFile := range configFiles {
-			if matchedPlugin, found := registry.FindByConfigFile(configFile); found && matchedPlugin == plugin {
-				matchingConfigs = append(matchingConfigs, configFile)
-			}
-		}
+	if err := validateResources(ctx, registry, resources); err != nil {
+		return err
+	}
 
-		if len(matchingConfigs) == 0 {
-			fmt.Printf("❌ Error: No configuration files found for plugin '%s'\n", pluginName)
-			fmt.Printf("Expected config pattern: %s\n", configPattern)
-			return fmt.Errorf("no config files found for plugin: %s", pluginName)
+	dispatched, _ := registry.Dispatch(resources)
+	if len(dispatched[pluginName]) == 0 {
+		fmt.Printf("❌ Error: No configuration documents found for plugin '%s'\n", pluginName)
+		fmt.Printf("Expected kinds: %s\n", strings.Join(plugin.Kinds(), ", "))
+		return fmt.Errorf("no config documents found for plugin: %s", pluginName)
+	}
+
+	fmt.Printf("🔧 Generating with %s plugin...\n", plugin.Name())
+	if err := plugin.Generate(ctx, dispatched[pluginName], out); err != nil {
+		return fmt.Errorf("failed to generate with plugin '%s': %w", pluginName, err)
+	}
+	if err := pruneWith(ctx, plugin, dispatched[pluginName], out, prune); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Successfully generated with %s plugin\n", plugin.Name())
+	return nil
+}
+
+// validateResources runs the generic document checks and every plugin
+// validator, printing each problem with its position.
+func validateResources(ctx context.Context, registry *plugin.Registry, resources []*config.Resource) error {
+	errs := config.Validate(resources)
+
+	dispatched, _ := registry.Dispatch(resources)
+	for _, validator := range registry.Validators() {
+		err := validator.Validate(ctx, dispatched[validator.Name()])
+		var list config.ErrorList
+		switch {
+		case err == nil:
+		case errors.As(err, &list):
+			errs = append(errs, list...)
+		default:
+			return fmt.Errorf("validation failed for plugin '%s': %w", validator.Name(), err)
 		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	errs.Sort()
+	for _, err := range errs {
+		fmt.Printf("❌ %s\n", err)
+	}
+	return fmt.Errorf("configuration is invalid: %d error(s)", len(errs))
+}
+
+// writeSchemas prints the combined schema, or writes one file per kind plus
+// the combined schema when outDir is set.
+func writeSchemas(registry *plugin.Registry, outDir string) error {
+	kinds := registry.KindTypes()
+	if outDir == "" {
+		return printJSON(os.Stdout, config.CombinedSchema(kinds))
+	}
 
-		for _, configFile := range matchingConfigs {
-			configPath := fileScanner.GetConfigPath(configFile)
-			fmt.Printf("🔧 Generating %s with %s plugin...\n", configFile, plugin.Name())
-			if err := plugin.Generate(ctx, configPath, outputDir); err != nil {
-				fmt.Printf("❌ Failed to generate with %s plugin for %s: %v\n", plugin.Name(), configFile, err)
-				continue
-			}
-			fmt.Printf("✅ Successfully generated %s\n", configFile)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %w", err)
+	}
+
+	files := map[string]interface{}{"dkn.schema.json": config.CombinedSchema(kinds)}
+	for kind, v := range kinds {
+		files[strings.ToLower(kind)+".schema.json"] = config.Schema(kind, v)
+	}
+
+	for name, schema := range files {
+		f, err := os.Create(filepath.Join(outDir, name))
+		if err != nil {
+			return fmt.Errorf("failed to write schema: %w", err)
 		}
-	} else {
-		// For plugins with exact config paths (like terraform/terraform.yaml)
-		configPath := fileScanner.GetConfigPath(plugin.ConfigFile())
-		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			fmt.Printf("❌ Error: Configuration file '%s' not found\n", plugin.ConfigFile())
-			fmt.Printf("Please create the required configuration file or use 'dkn' without arguments to scan for all configs.\n")
-			return fmt.Errorf("config file not found: %s", plugin.ConfigFile())
+		err = printJSON(f, schema)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write schema %s: %w", name, err)
 		}
+		fmt.Printf("✅ Wrote %s\n", filepath.Join(outDir, name))
+	}
+	return nil
+}
 
-		fmt.Printf("🔧 Generating with %s plugin...\n", plugin.Name())
-		if err := plugin.Generate(ctx, configPath, outputDir); err != nil {
-			return fmt.Errorf("failed to generate with plugin '%s': %w", pluginName, err)
-		}
-		fmt.Printf("✅ Successfully generated with %s plugin\n", plugin.Name())
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// pruneWith removes the files a plugin no longer generates when pruning was
+// asked for and the plugin supports it.
+func pruneWith(ctx context.Context, p plugin.Plugin, resources []*config.Resource, out *output.Writer, prune bool) error {
+	pruner, ok := p.(plugin.Pruner)
+	if !prune || !ok {
+		return nil
+	}
+	if err := pruner.Prune(ctx, resources, out); err != nil {
+		return fmt.Errorf("failed to prune with plugin '%s': %w", p.Name(), err)
 	}
 	return nil
 }
 
-func scanAndGenerate(ctx context.Context, registry *plugin.Registry, fileScanner *scanner.FileScanner, outputDir string) error {
-	configFiles, err := fileScanner.ScanForConfigs()
+func scanAndGenerate(ctx context.Context, registry *plugin.Registry, fileScanner *scanner.FileScanner, out *output.Writer, prune bool) error {
+	resources, err := fileScanner.LoadConfigs()
 	if err != nil {
-		return fmt.Errorf("failed to scan for config files: %w", err)
+		return fmt.Errorf("failed to load config files: %w", err)
 	}
 
-	if len(configFiles) == 0 {
+	if len(resources) == 0 {
 		fmt.Println("ℹ️  No configuration files found in current directory")
-		fmt.Println("\nSupported configuration patterns:")
-		for name, plugin := range registry.All() {
-			fmt.Printf("  - %s: %s\n", name, plugin.ConfigFile())
+		fmt.Println("\nSupported kinds:")
+		for _, name := range registry.Names() {
+			plugin, _ := registry.Get(name)
+			fmt.Printf("  - %s: %s\n", name, strings.Join(plugin.Kinds(), ", "))
 		}
 		return nil
 	}
 
-	for _, configFile := range configFiles {
-		plugin, found := registry.FindByConfigFile(configFile)
-		if !found {
-			fmt.Printf("⚠️  No plugin found for config file: %s\n", configFile)
+	if err := validateResources(ctx, registry, resources); err != nil {
+		return err
+	}
+
+	dispatched, unhandled := registry.Dispatch(resources)
+	for _, resource := range unhandled {
+		fmt.Printf("⚠️  No plugin handles kind '%s' (%s)\n", resource.Kind, resource.Location())
+	}
+
+	// Each plugin runs once per pass with every document it handles.
+	for _, name := range registry.Names() {
+		if len(dispatched[name]) == 0 {
 			continue
 		}
 
-		configPath := fileScanner.GetConfigPath(configFile)
+		plugin, _ := registry.Get(name)
 		fmt.Printf("🔧 Generating with %s plugin...\n", plugin.Name())
 
-		if err := plugin.Generate(ctx, configPath, outputDir); err != nil {
+		if err := plugin.Generate(ctx, dispatched[name], out); err != nil {
 			fmt.Printf("❌ Failed to generate with %s plugin: %v\n", plugin.Name(), err)
 			continue
 		}
+		if err := pruneWith(ctx, plugin, dispatched[name], out, prune); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			continue
+		}
 		fmt.Printf("✅ Successfully generated with %s plugin\n", plugin.Name())
 	}
 
@@ -110,6 +188,215 @@ func scanAndGenerate(ctx context.Context, registry *plugin.Registry, fileScanner
 	return nil
 }
 
+func newRegistry() *plugin.Registry {
+	registry := plugin.NewRegistry()
+	registry.Register(terraform.New())
+	return registry
+}
+
+// generateInto runs the plugin named by the first argument, or every plugin,
+// writing through out.
+func generateInto(c *cli.Context, out *output.Writer) error {
+	fileScanner := scanner.NewFileScanner(out.Root())
+	ctx := c.Context
+
+	var err error
+	if c.Args().Present() {
+		err = runPlugin(ctx, newRegistry(), fileScanner, out, c.Bool("prune"), c.Args().First())
+	} else {
+		err = scanAndGenerate(ctx, newRegistry(), fileScanner, out, c.Bool("prune"))
+	}
+	if err != nil {
+		return err
+	}
+	return out.Commit()
+}
+
+func generateAction(c *cli.Context) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	newWriter := output.NewWriter
+	if c.Bool("dry-run") {
+		newWriter = output.NewDryRunWriter
+	}
+	out, err := newWriter(cwd, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	// A dry run lists its changes even when edited files would stop the
+	// real run, then reports them the same way.
+	err = generateInto(c, out)
+	var conflicts *output.ConflictError
+	if !c.Bool("dry-run") || (err != nil && !errors.As(err, &conflicts)) {
+		return err
+	}
+
+	changes, err := out.Changes()
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		if conflicts != nil {
+			return conflicts
+		}
+		fmt.Println("✅ Generated files are up to date")
+		return nil
+	}
+	fmt.Println("Dry run, nothing was written. Would change:")
+	for _, change := range changes {
+		action := "update"
+		switch {
+		case change.Old == nil:
+			action = "create"
+		case change.New == nil:
+			action = "delete"
+		}
+		fmt.Printf("  %s %s\n", action, change.Path)
+	}
+	if conflicts != nil {
+		return conflicts
+	}
+	return nil
+}
+
+// diffAction generates into memory and prints how the result differs from
+// the files on disk, including generated files that were edited by hand.
+func diffAction(c *cli.Context) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	out, err := output.NewDryRunWriter(cwd, true)
+	if err != nil {
+		return err
+	}
+	if err := generateInto(c, out); err != nil {
+		return err
+	}
+
+	changes, err := out.Changes()
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Print(change.Diff())
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("✅ Generated files are up to date")
+	} else if c.Bool("check") {
+		return cli.Exit(fmt.Sprintf("❌ %d generated file(s) are out of date, run dkn gen", len(changes)), 1)
+	}
+	return nil
+}
+
+func generateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Overwrite generated files even if they were edited by hand",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Generate in memory and list the files that would change",
+		},
+		&cli.BoolFlag{
+			Name:  "prune",
+			Usage: "Remove generated files for components and environments that no longer exist",
+		},
+	}
+}
+
+func lifecycleOptions(c *cli.Context) (plugin.Options, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return plugin.Options{}, fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	return plugin.Options{
+		DeployPath:  "deploy",
+		OutputDir:   cwd,
+		Component:   c.String("name"),
+		Environment: c.String("environment"),
+		Parallelism: c.Int("parallelism"),
+		UsePlan:     c.Bool("use-plan"),
+		Timeout:     c.Duration("timeout"),
+		KillAfter:   c.Duration("kill-after"),
+		AutoApprove: c.Bool("auto-approve"),
+		AllowCI:     c.Bool("allow-ci"),
+		Confirm:     c.String("confirm"),
+	}, nil
+}
+
+// runLifecycle invokes an operation on every plugin that implements it,
+// stopping at the first failure.
+func runLifecycle[T plugin.Plugin](plugins []T, operation string, run func(T) error) error {
+	if len(plugins) == 0 {
+		fmt.Printf("ℹ️  No plugins support %s\n", operation)
+		return nil
+	}
+
+	for _, p := range plugins {
+		if err := run(p); err != nil {
+			return fmt.Errorf("%s failed for plugin '%s': %w", operation, p.Name(), err)
+		}
+	}
+	return nil
+}
+
+// interruptible runs a lifecycle operation that drives Terraform with the
+// two-stage signal handling of plugin.Interruptible, so the first Ctrl-C
+// lets running commands stop cleanly. Other commands keep Go's default
+// handling and stop at once.
+func interruptible(c *cli.Context, run func(ctx context.Context) error) error {
+	ctx, stop := plugin.Interruptible(c.Context)
+	defer stop()
+	return run(ctx)
+}
+
+func nameFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "name",
+		Aliases: []string{"n"},
+		Usage:   "Name of the component (optional, defaults to all components)",
+	}
+}
+
+func environmentFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:     "environment",
+		Aliases:  []string{"e"},
+		Usage:    "Environment to operate on",
+		Required: true,
+	}
+}
+
+func timeoutFlag() cli.Flag {
+	return &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Stop a component's Terraform commands after this long, e.g. 30m (defaults to no limit)",
+	}
+}
+
+func killAfterFlag() cli.Flag {
+	return &cli.DurationFlag{
+		Name:  "kill-after",
+		Usage: "Kill a Terraform command that has not stopped this long after being interrupted (defaults to 1m)",
+	}
+}
+
+func confirmFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "confirm",
+		Usage: "Approve by repeating the environment name instead of being prompted",
+	}
+}
+
 func main() {
 	app := &cli.App{
 		Name:        "dkn",
@@ -121,86 +408,154 @@ func main() {
 				Name:    "generate",
 				Aliases: []string{"gen"},
 				Usage:   "Generate configurations",
+				Flags:   generateFlags(),
+				Action:  generateAction,
+			},
+			{
+				Name:      "diff",
+				Usage:     "Show how generated files differ from the files on disk",
+				ArgsUsage: "[plugin]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "Exit non-zero when any generated file is out of date",
+					},
+					&cli.BoolFlag{
+						Name:  "prune",
+						Usage: "Include generated files that would be removed by gen --prune",
+					},
+				},
+				Action: diffAction,
+			},
+			{
+				Name:  "validate",
+				Usage: "Validate configuration files",
 				Action: func(c *cli.Context) error {
 					cwd, err := os.Getwd()
 					if err != nil {
 						return fmt.Errorf("failed to get current directory: %w", err)
 					}
 
-					registry := plugin.NewRegistry()
-					registry.Register(terraform.New())
-
-					fileScanner := scanner.NewFileScanner(cwd)
-					ctx := context.Background()
-					outputDir := cwd
+					resources, err := scanner.NewFileScanner(cwd).LoadConfigs()
+					if err != nil {
+						return fmt.Errorf("failed to load config files: %w", err)
+					}
 
-					return scanAndGenerate(ctx, registry, fileScanner, outputDir)
+					if err := validateResources(c.Context, newRegistry(), resources); err != nil {
+						return err
+					}
+					fmt.Printf("✅ Configuration is valid (%d documents)\n", len(resources))
+					return nil
+				},
+			},
+			{
+				Name:  "schema",
+				Usage: "Export JSON Schema for every configuration kind",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "out",
+						Aliases: []string{"o"},
+						Usage:   "Directory to write one schema per kind into (defaults to printing the combined schema)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return writeSchemas(newRegistry(), c.String("out"))
+				},
+			},
+			{
+				Name:  "plan",
+				Usage: "Preview configuration changes and save a plan per component",
+				Flags: []cli.Flag{nameFlag(), environmentFlag(), timeoutFlag(), killAfterFlag()},
+				Action: func(c *cli.Context) error {
+					opts, err := lifecycleOptions(c)
+					if err != nil {
+						return err
+					}
+					return interruptible(c, func(ctx context.Context) error {
+						return runLifecycle(newRegistry().Planners(), "plan", func(p plugin.Planner) error {
+							return p.Plan(ctx, opts)
+						})
+					})
 				},
 			},
 			{
 				Name:  "apply",
 				Usage: "Apply configuration changes",
 				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "name",
-						Aliases: []string{"n"},
-						Usage:   "Name of the component to apply (optional)",
+					nameFlag(),
+					environmentFlag(),
+					&cli.IntFlag{
+						Name:  "parallelism",
+						Usage: "Number of independent components to apply at once",
+						Value: 1,
 					},
-					&cli.StringFlag{
-						Name:     "environment",
-						Aliases:  []string{"e"},
-						Usage:    "Environment to apply to",
-						Required: true,
+					&cli.BoolFlag{
+						Name:  "use-plan",
+						Usage: "Apply the plans saved by 'dkn plan' instead of planning again",
+					},
+					&cli.BoolFlag{
+						Name:  "auto-approve",
+						Usage: "Apply without asking, unless the environment requires approval",
 					},
+					confirmFlag(),
+					timeoutFlag(),
+					killAfterFlag(),
 				},
 				Action: func(c *cli.Context) error {
-					cwd, err := os.Getwd()
+					opts, err := lifecycleOptions(c)
 					if err != nil {
-						return fmt.Errorf("failed to get current directory: %w", err)
+						return err
 					}
-
-					terraformPlugin := terraform.New()
-					ctx := context.Background()
-					
-					deployPath := "deploy"
-					component := c.String("name")
-					environment := c.String("environment")
-
-					// If no component name provided, apply all components
-					if component == "" {
-						config, err := terraform.LoadConfig(deployPath)
-						if err != nil {
-							return fmt.Errorf("failed to load config: %w", err)
-						}
-						
-						for _, comp := range config.Components {
-							fmt.Printf("🚀 Applying component: %s\n", comp.Metadata.Name)
-							if err := terraformPlugin.Apply(ctx, deployPath, cwd, comp.Metadata.Name, environment); err != nil {
-								return fmt.Errorf("failed to apply component %s: %w", comp.Metadata.Name, err)
-							}
-						}
-						return nil
+					return interruptible(c, func(ctx context.Context) error {
+						return runLifecycle(newRegistry().Appliers(), "apply", func(a plugin.Applier) error {
+							return a.Apply(ctx, opts)
+						})
+					})
+				},
+			},
+			{
+				Name:  "destroy",
+				Usage: "Destroy deployed resources",
+				Flags: []cli.Flag{
+					nameFlag(),
+					environmentFlag(),
+					&cli.BoolFlag{
+						Name:  "allow-ci",
+						Usage: "Allow destroying when running in CI",
+					},
+					confirmFlag(),
+					timeoutFlag(),
+					killAfterFlag(),
+				},
+				Action: func(c *cli.Context) error {
+					opts, err := lifecycleOptions(c)
+					if err != nil {
+						return err
 					}
-
-					return terraformPlugin.Apply(ctx, deployPath, cwd, component, environment)
+					return interruptible(c, func(ctx context.Context) error {
+						return runLifecycle(newRegistry().Destroyers(), "destroy", func(d plugin.Destroyer) error {
+							return d.Destroy(ctx, opts)
+						})
+					})
+				},
+			},
+			{
+				Name:  "clean",
+				Usage: "Remove local working files and generated files that are no longer configured",
+				Flags: []cli.Flag{nameFlag()},
+				Action: func(c *cli.Context) error {
+					opts, err := lifecycleOptions(c)
+					if err != nil {
+						return err
+					}
+					return runLifecycle(newRegistry().Cleaners(), "clean", func(cl plugin.Cleaner) error {
+						return cl.Clean(c.Context, opts)
+					})
 				},
 			},
 		},
-		Action: func(c *cli.Context) error {
-			cwd, err := os.Getwd()
-			if err != nil {
-				return fmt.Errorf("failed to get current directory: %w", err)
-			}
-
-			registry := plugin.NewRegistry()
-			registry.Register(terraform.New())
-
-			fileScanner := scanner.NewFileScanner(cwd)
-			ctx := context.Background()
-			outputDir := cwd
-
-			return scanAndGenerate(ctx, registry, fileScanner, outputDir)
-		},
+		Flags:  generateFlags(),
+		Action: generateAction,
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Errorf reports a problem at the node addressed by path, such as
+// "metadata.name" or "spec.environmentRefs[1]". When the path does not
+// exist the closest existing parent is used.
+func (r *Resource) Errorf(path string, format string, args ...interface{}) *Error {
+	node := r.lookup(path)
+	return &Error{
+		File:    r.File,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Check compares the document against the Go type of v and reports unknown
+// fields and values of the wrong type.
+func (r *Resource) Check(v interface{}) ErrorList {
+	var errs ErrorList
+	r.check(r.root(), reflect.TypeOf(v), "", &errs)
+	return errs
+}
+
+func (r *Resource) root() *yaml.Node {
+	if r.node.Kind == yaml.DocumentNode && len(r.node.Content) > 0 {
+		return r.node.Content[0]
+	}
+	return r.node
+}
+
+func (r *Resource) lookup(path string) *yaml.Node {
+	node := r.root()
+	for _, segment := range splitPath(path) {
+		next := child(node, segment)
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return node
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		for {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				segments = append(segments, part[:i])
+			}
+			j := strings.Index(part, "]")
+			if j < i {
+				break
+			}
+			segments = append(segments, part[i:j+1])
+			part = part[j+1:]
+		}
+		if part != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+func child(node *yaml.Node, segment string) *yaml.Node {
+	if strings.HasPrefix(segment, "[") {
+		index, err := strconv.Atoi(strings.Trim(segment, "[]"))
+		if err != nil || node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+			return nil
+		}
+		return node.Content[index]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == segment {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+func (r *Resource) check(node *yaml.Node, t reflect.Type, path string, errs *ErrorList) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Tag == "!!null" || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	mismatch := func(expected string) {
+		*errs = append(*errs, &Error{
+			File:    r.File,
+			Line:    node.Line,
+			Column:  node.Column,
+			Message: fmt.Sprintf("%s: expected %s, got %s", displayPath(path), expected, describe(node)),
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			mismatch("a mapping")
+			return
+		}
+		fields := structFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				*errs = append(*errs, &Error{
+					File:    r.File,
+					Line:    key.Line,
+					Column:  key.Column,
+					Message: fmt.Sprintf("%s: unknown field %q", displayPath(path), key.Value),
+				})
+				continue
+			}
+			r.check(value, field, joinPath(path, key.Value), errs)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			mismatch("a mapping")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			r.check(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			mismatch("a list")
+			return
+		}
+		for i, item := range node.Content {
+			r.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			mismatch("a string")
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			mismatch("a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			mismatch("an integer")
+		}
+	case reflect.Float32, reflect.Float64:
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			mismatch("a number")
+		}
+	}
+}
+
+// structFields maps YAML keys to field types the way yaml.v3 does,
+// including inlined structs.
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			inner := field.Type
+			if inner.Kind() == reflect.Ptr {
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct {
+				for k, v := range structFields(inner) {
+					fields[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func describe(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return fmt.Sprintf("%s %q", strings.TrimPrefix(node.Tag, "!!"), node.Value)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "document"
+	}
+	return path
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+func decodeYAML(data []byte) ([]*yaml.Node, error) {
+	var nodes []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return nodes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &node)
+	}
+}
+
+// decodeJSON splits a stream of concatenated JSON values and parses each
+// one as YAML, shifting node positions so they refer to the original file.
+func decodeJSON(data []byte) ([]*yaml.Node, error) {
+	var nodes []*yaml.Node
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return nodes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		end := int(decoder.InputOffset())
+		start := end - len(raw)
+
+		var node yaml.Node
+		if err := yaml.Unmarshal(raw, &node); err != nil {
+			return nil, err
+		}
+		line := bytes.Count(data[:start], []byte("\n"))
+		column := start - (bytes.LastIndexByte(data[:start], '\n') + 1)
+		shiftPositions(&node, line, column)
+		nodes = append(nodes, &node)
+	}
+}
+
+// shiftPositions moves a node tree parsed from a fragment to its position
+// in the enclosing file. Only the fragment's first line is offset by column.
+func shiftPositions(node *yaml.Node, line, column int) {
+	if node.Line == 1 {
+		node.Column += column
+	}
+	node.Line += line
+	for _, child := range node.Content {
+		shiftPositions(child, line, column)
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Metadata struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Labels      map[string]string `yaml:"labels"`
+}
+
+// Resource is a single configuration document. Only the kind and metadata
+// are decoded up front; plugins decode the rest into their own types.
+type Resource struct {
+	Kind     string
+	Metadata Metadata
+	// File and Index locate the document: Index is its zero-based
+	// position within a multi-document file.
+	File  string
+	Index int
+	node  *yaml.Node
+}
+
+func (r *Resource) Decode(v interface{}) error {
+	return r.node.Decode(v)
+}
+
+// Location describes where the document came from for use in messages.
+func (r *Resource) Location() string {
+	if r.Index == 0 {
+		return r.File
+	}
+	return fmt.Sprintf("%s (document %d)", r.File, r.Index+1)
+}
+
+func IsConfigFile(filename string) bool {
+	switch filepath.Ext(filename) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// LoadFile decodes every document in a YAML stream or JSON file.
+func LoadFile(path string) ([]*Resource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*yaml.Node
+	if strings.HasSuffix(path, ".json") {
+		nodes, err = decodeJSON(data)
+	} else {
+		nodes, err = decodeYAML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var resources []*Resource
+	for index, node := range nodes {
+		if isEmpty(node) {
+			continue
+		}
+
+		var header struct {
+			Kind     string   `yaml:"kind"`
+			Metadata Metadata `yaml:"metadata"`
+		}
+		if err := node.Decode(&header); err != nil {
+			return nil, fmt.Errorf("failed to parse %s (document %d): %w", path, index+1, err)
+		}
+
+		resources = append(resources, &Resource{
+			Kind:     header.Kind,
+			Metadata: header.Metadata,
+			File:     path,
+			Index:    index,
+			node:     node,
+		})
+	}
+	return resources, nil
+}
+
+// LoadDir loads every configuration document found below dir.
+func LoadDir(dir string) ([]*Resource, error) {
+	var resources []*Resource
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !IsConfigFile(d.Name()) {
+			return nil
+		}
+
+		loaded, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, loaded...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+func isEmpty(node *yaml.Node) bool {
+	if node.Kind == yaml.DocumentNode {
+		return len(node.Content) == 0 || isEmpty(node.Content[0])
+	}
+	return node.Kind == 0 || node.Tag == "!!null"
+}
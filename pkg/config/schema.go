@@ -0,0 +1,104 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+const schemaDialect = "http://json-schema.org/draft-07/schema#"
+
+// Schema derives a JSON Schema for documents of the given kind from the Go
+// type they decode into, so the schema cannot drift from the code.
+func Schema(kind string, v interface{}) map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(v), make(map[reflect.Type]bool))
+	schema["$schema"] = schemaDialect
+	schema["title"] = kind
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	if properties == nil {
+		properties = make(map[string]interface{})
+		schema["properties"] = properties
+	}
+	properties["kind"] = map[string]interface{}{"const": kind}
+	if metadata, ok := properties["metadata"].(map[string]interface{}); ok {
+		metadata["required"] = []string{"name"}
+	}
+	schema["required"] = []string{"kind", "metadata"}
+	return schema
+}
+
+// CombinedSchema accepts a document of any of the given kinds. It is meant
+// for editors such as the YAML language server that take a single schema.
+func CombinedSchema(kinds map[string]interface{}) map[string]interface{} {
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+
+	definitions := make(map[string]interface{})
+	var oneOf []interface{}
+	for _, kind := range names {
+		definition := Schema(kind, kinds[kind])
+		delete(definition, "$schema")
+		definitions[kind] = definition
+		oneOf = append(oneOf, map[string]interface{}{"$ref": "#/definitions/" + kind})
+	}
+
+	return map[string]interface{}{
+		"$schema":     schemaDialect,
+		"title":       "dkn",
+		"definitions": definitions,
+		"oneOf":       oneOf,
+	}
+}
+
+// typeSchema tracks the structs being expanded so recursive types such as
+// nested blocks fall back to a plain object instead of recursing forever.
+func typeSchema(t reflect.Type, expanding map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if expanding[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		expanding[t] = true
+		defer delete(expanding, t)
+
+		properties := make(map[string]interface{})
+		for name, field := range structFields(t) {
+			properties[name] = typeSchema(field, expanding)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), expanding),
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), expanding),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,27 @@
+package config
+
+// Validate performs the checks that apply to every document regardless of
+// kind: a kind, a name, and names that are unique per kind.
+func Validate(resources []*Resource) ErrorList {
+	var errs ErrorList
+	seen := make(map[string]*Resource)
+	for _, resource := range resources {
+		if resource.Kind == "" {
+			errs = append(errs, resource.Errorf("kind", "missing kind"))
+			continue
+		}
+		if resource.Metadata.Name == "" {
+			errs = append(errs, resource.Errorf("metadata.name", "%s is missing metadata.name", resource.Kind))
+			continue
+		}
+
+		key := resource.Kind + "/" + resource.Metadata.Name
+		if first, ok := seen[key]; ok {
+			position := first.Errorf("metadata.name", "")
+			errs = append(errs, resource.Errorf("metadata.name", "duplicate %s %q, first defined at %s:%d", resource.Kind, resource.Metadata.Name, position.File, position.Line))
+			continue
+		}
+		seen[key] = resource
+	}
+	return errs
+}
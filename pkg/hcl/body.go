@@ -0,0 +1,136 @@
+package hcl
+
+import "strings"
+
+type attribute struct {
+	name  string
+	value Value
+}
+
+// Body holds attributes and nested blocks in the order they were added.
+type Body struct {
+	items []interface{}
+}
+
+// SetAttribute replaces an existing attribute of the same name in place, or
+// appends a new one.
+func (b *Body) SetAttribute(name string, value Value) {
+	for i, item := range b.items {
+		if attr, ok := item.(*attribute); ok && attr.name == name {
+			b.items[i] = &attribute{name: name, value: value}
+			return
+		}
+	}
+	b.items = append(b.items, &attribute{name: name, value: value})
+}
+
+// SetAttributes sets every entry of values in sorted key order.
+func (b *Body) SetAttributes(values map[string]Value) {
+	o := Object(values).(object)
+	for _, attr := range o.attributes {
+		b.SetAttribute(attr.name, attr.value)
+	}
+}
+
+func (b *Body) AppendBlock(typ string, labels ...string) *Block {
+	block := &Block{Type: typ, Labels: labels}
+	b.items = append(b.items, block)
+	return block
+}
+
+func (b *Body) Empty() bool {
+	return len(b.items) == 0
+}
+
+type Block struct {
+	Type   string
+	Labels []string
+	Body
+}
+
+// File is a complete HCL document with optional leading comment lines.
+type File struct {
+	Comments []string
+	Body
+}
+
+func NewFile(comments ...string) *File {
+	return &File{Comments: comments}
+}
+
+// Bytes renders the file the way terraform fmt would lay it out: two-space
+// indentation, aligned equals signs and blank lines around blocks.
+func (f *File) Bytes() []byte {
+	var b strings.Builder
+	for _, comment := range f.Comments {
+		b.WriteString("# " + comment + "\n")
+	}
+	f.Body.write(&b, "")
+	return []byte(b.String())
+}
+
+func (body *Body) write(b *strings.Builder, indent string) {
+	var run []attribute
+	flush := func() {
+		writeAttributes(b, indent, run)
+		run = nil
+	}
+
+	for i, item := range body.items {
+		switch item := item.(type) {
+		case *attribute:
+			if i > 0 && len(run) == 0 {
+				b.WriteString("\n")
+			}
+			run = append(run, *item)
+		case *Block:
+			flush()
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			item.write(b, indent)
+		}
+	}
+	flush()
+}
+
+func (block *Block) write(b *strings.Builder, indent string) {
+	b.WriteString(indent + block.Type)
+	for _, label := range block.Labels {
+		b.WriteString(" " + quote(label, true))
+	}
+	if block.Empty() {
+		b.WriteString(" {}\n")
+		return
+	}
+	b.WriteString(" {\n")
+	block.Body.write(b, indent+"  ")
+	b.WriteString(indent + "}\n")
+}
+
+// writeAttributes aligns the equals signs of consecutive attributes. As
+// with terraform fmt, an attribute whose value spans several lines is not
+// aligned with its neighbours.
+func writeAttributes(b *strings.Builder, indent string, attributes []attribute) {
+	for start := 0; start < len(attributes); {
+		end := start + 1
+		if !attributes[start].value.multiline() {
+			for end < len(attributes) && !attributes[end].value.multiline() {
+				end++
+			}
+		}
+
+		width := 0
+		for _, attr := range attributes[start:end] {
+			if len(attr.name) > width {
+				width = len(attr.name)
+			}
+		}
+		for _, attr := range attributes[start:end] {
+			b.WriteString(indent + attr.name + strings.Repeat(" ", width-len(attr.name)) + " = ")
+			attr.value.write(b, indent)
+			b.WriteString("\n")
+		}
+		start = end
+	}
+}
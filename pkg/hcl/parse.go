@@ -0,0 +1,80 @@
+package hcl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RawAttribute is a top-level assignment read back from a file such as
+// tfvars, with its expression kept as written.
+type RawAttribute struct {
+	Name string
+	Expr string
+}
+
+var assignmentStart = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*)\s*=\s*(.*)$`)
+
+// ParseAttributes reads the top-level attributes of a file made only of
+// assignments. Comments between assignments are dropped. Blocks and heredoc
+// strings are not supported.
+func ParseAttributes(src []byte) ([]RawAttribute, error) {
+	var attributes []RawAttribute
+	lines := strings.Split(string(src), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		match := assignmentStart.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: expected an attribute assignment", i+1)
+		}
+
+		start := i
+		expr := match[2]
+		depth, err := nesting(expr, 0)
+		for err == nil && depth > 0 && i+1 < len(lines) {
+			i++
+			expr += "\n" + lines[i]
+			depth, err = nesting(lines[i], depth)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if depth > 0 {
+			return nil, fmt.Errorf("line %d: unterminated value for %s", start+1, match[1])
+		}
+		attributes = append(attributes, RawAttribute{Name: match[1], Expr: strings.TrimRight(expr, " \t")})
+	}
+	return attributes, nil
+}
+
+// nesting returns the bracket depth after line, starting from depth.
+// Brackets inside strings and comments are ignored.
+func nesting(line string, depth int) (int, error) {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '#' || strings.HasPrefix(line[i:], "//"):
+			return depth, nil
+		case strings.HasPrefix(line[i:], "<<"):
+			return depth, fmt.Errorf("heredoc strings are not supported")
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		}
+	}
+	if inString {
+		return depth, fmt.Errorf("unterminated string")
+	}
+	return depth, nil
+}
@@ -0,0 +1,235 @@
+package hcl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value is an HCL expression that can be assigned to an attribute.
+type Value interface {
+	// write renders the value, indenting continuation lines by indent.
+	write(b *strings.Builder, indent string)
+	multiline() bool
+}
+
+type literal string
+
+func (l literal) write(b *strings.Builder, indent string) { b.WriteString(string(l)) }
+func (l literal) multiline() bool                         { return false }
+
+// String is a quoted string whose content is taken literally, so template
+// sequences such as ${ are escaped.
+func String(s string) Value {
+	return literal(quote(s, true))
+}
+
+// Template is a quoted string template; ${...} and %{...} sequences are
+// kept so Terraform interpolates them.
+func Template(s string) Value {
+	return literal(quote(s, false))
+}
+
+// Expr is an expression written verbatim, such as var.name or a function
+// call.
+func Expr(expr string) Value {
+	return literal(expr)
+}
+
+func Bool(v bool) Value {
+	return literal(strconv.FormatBool(v))
+}
+
+func Int(v int64) Value {
+	return literal(strconv.FormatInt(v, 10))
+}
+
+func Float(v float64) Value {
+	return literal(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+func Null() Value {
+	return literal("null")
+}
+
+type list []Value
+
+// List renders on one line unless an item spans several lines.
+func List(items ...Value) Value {
+	return list(items)
+}
+
+func (l list) multiline() bool {
+	for _, item := range l {
+		if item.multiline() {
+			return true
+		}
+	}
+	return false
+}
+
+func (l list) write(b *strings.Builder, indent string) {
+	if !l.multiline() {
+		b.WriteString("[")
+		for i, item := range l {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			item.write(b, indent)
+		}
+		b.WriteString("]")
+		return
+	}
+
+	b.WriteString("[\n")
+	for _, item := range l {
+		b.WriteString(indent + "  ")
+		item.write(b, indent+"  ")
+		b.WriteString(",\n")
+	}
+	b.WriteString(indent + "]")
+}
+
+type object struct {
+	attributes []attribute
+}
+
+// Object renders a map as an object constructor with keys in sorted order.
+func Object(values map[string]Value) Value {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	o := object{}
+	for _, key := range keys {
+		o.attributes = append(o.attributes, attribute{name: objectKey(key), value: values[key]})
+	}
+	return o
+}
+
+func (o object) multiline() bool {
+	return len(o.attributes) > 0
+}
+
+func (o object) write(b *strings.Builder, indent string) {
+	if len(o.attributes) == 0 {
+		b.WriteString("{}")
+		return
+	}
+	b.WriteString("{\n")
+	writeAttributes(b, indent+"  ", o.attributes)
+	b.WriteString(indent + "}")
+}
+
+type index struct {
+	collection Value
+	key        Value
+}
+
+// Index selects an element of a collection, such as a per-environment
+// object indexed by var.environment.
+func Index(collection, key Value) Value {
+	return index{collection, key}
+}
+
+func (i index) multiline() bool {
+	return i.collection.multiline()
+}
+
+func (i index) write(b *strings.Builder, indent string) {
+	i.collection.write(b, indent)
+	b.WriteString("[")
+	i.key.write(b, indent)
+	b.WriteString("]")
+}
+
+// Literal converts a value decoded from YAML or JSON. Strings are taken
+// literally.
+func Literal(v interface{}) Value {
+	return convert(v, String)
+}
+
+// Interpolated converts a value decoded from YAML or JSON, treating strings
+// as templates so they can reference variables and other resources.
+func Interpolated(v interface{}) Value {
+	return convert(v, Template)
+}
+
+func convert(v interface{}, str func(string) Value) Value {
+	switch value := v.(type) {
+	case nil:
+		return Null()
+	case Value:
+		return value
+	case string:
+		return str(value)
+	case bool:
+		return Bool(value)
+	case int:
+		return Int(int64(value))
+	case int64:
+		return Int(value)
+	case uint64:
+		return literal(strconv.FormatUint(value, 10))
+	case float64:
+		return Float(value)
+	case []interface{}:
+		items := make([]Value, len(value))
+		for i, item := range value {
+			items[i] = convert(item, str)
+		}
+		return List(items...)
+	case map[string]interface{}:
+		values := make(map[string]Value, len(value))
+		for key, item := range value {
+			values[key] = convert(item, str)
+		}
+		return Object(values)
+	}
+	return str(fmt.Sprint(v))
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func objectKey(key string) string {
+	if identifier.MatchString(key) {
+		return key
+	}
+	return quote(key, true)
+}
+
+func quote(s string, escapeTemplates bool) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '$', '%':
+			b.WriteRune(r)
+			if escapeTemplates && strings.HasPrefix(s[i+1:], "{") {
+				b.WriteRune(r)
+			}
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
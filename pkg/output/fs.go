@@ -0,0 +1,105 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fileSystem is where a Writer reads and writes files, by path relative to
+// the output root.
+type fileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	Remove(name string) error
+}
+
+type dirFS string
+
+func (d dirFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(d), name))
+}
+
+func (d dirFS) WriteFile(name string, data []byte) error {
+	path := filepath.Join(string(d), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Remove deletes a file and then any directories it leaves empty, up to
+// the root.
+func (d dirFS) Remove(name string) error {
+	if err := os.Remove(filepath.Join(string(d), name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(name); dir != "."; dir = filepath.Dir(dir) {
+		if os.Remove(filepath.Join(string(d), dir)) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// memFS keeps written files in memory and reads everything else from base.
+// A removed file is kept as a nil entry.
+type memFS struct {
+	base  fileSystem
+	files map[string][]byte
+}
+
+func newMemFS(base fileSystem) *memFS {
+	return &memFS{base: base, files: make(map[string][]byte)}
+}
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	data, ok := m.files[filepath.ToSlash(name)]
+	switch {
+	case !ok:
+		return m.base.ReadFile(name)
+	case data == nil:
+		return nil, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (m *memFS) WriteFile(name string, data []byte) error {
+	m.files[filepath.ToSlash(name)] = append([]byte{}, data...)
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	m.files[filepath.ToSlash(name)] = nil
+	return nil
+}
+
+// Change is a file whose generated content differs from what is on disk.
+// Old is nil for a file that does not exist yet, New for one that would be
+// removed.
+type Change struct {
+	Path string
+	Old  []byte
+	New  []byte
+}
+
+// changes compares every file written to memory with base, in path order.
+func (m *memFS) changes() ([]Change, error) {
+	var changes []Change
+	for name, data := range m.files {
+		old, err := m.base.ReadFile(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if bytes.Equal(old, data) && (old == nil) == (data == nil) {
+			continue
+		}
+		changes = append(changes, Change{Path: name, Old: old, New: data})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
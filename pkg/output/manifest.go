@@ -0,0 +1,71 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ManifestPath is where the manifest lives, relative to the output root.
+const ManifestPath = ".dkn/manifest.json"
+
+// Manifest records every file dkn generated, keyed by its slash-separated
+// path relative to the output root.
+type Manifest struct {
+	Version int              `json:"version"`
+	Files   map[string]Entry `json:"files"`
+}
+
+// Entry is a generated file. Checksum covers the content outside user
+// regions; Keys lists the assignments dkn owns in files that are merged
+// rather than overwritten.
+type Entry struct {
+	Checksum string   `json:"checksum"`
+	Keys     []string `json:"keys,omitempty"`
+}
+
+func newManifest() *Manifest {
+	return &Manifest{Version: 1, Files: make(map[string]Entry)}
+}
+
+// LoadManifest reads the manifest under root. A missing manifest is empty.
+func LoadManifest(root string) (*Manifest, error) {
+	return loadManifest(dirFS(root))
+}
+
+func loadManifest(fsys fileSystem) (*Manifest, error) {
+	data, err := fsys.ReadFile(ManifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newManifest(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	manifest := newManifest()
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestPath, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]Entry)
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) save(fsys fileSystem) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFile(ManifestPath, append(data, '\n'))
+}
+
+// Checksum hashes data with the content of its user regions left out, so
+// edits inside a region do not count as changes to the generated file.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(stripRegions(data))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package output
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// A user region is a block of lines between two marker comments that dkn
+// leaves untouched when it regenerates a file:
+//
+//	# dkn:user-begin extra
+//	...
+//	# dkn:user-end extra
+var regionMarker = regexp.MustCompile(`dkn:user-(begin|end) ([A-Za-z0-9_.-]+)`)
+
+// Region returns an empty user region called name, using comment as the
+// line comment prefix of the target language.
+func Region(comment, name string) string {
+	return comment + " dkn:user-begin " + name + "\n" + comment + " dkn:user-end " + name + "\n"
+}
+
+// regions returns the lines inside each user region of data.
+func regions(data []byte) map[string][]byte {
+	found := make(map[string][]byte)
+	var name string
+	var content []byte
+	for _, line := range splitLines(data) {
+		marker := regionMarker.FindSubmatch(line)
+		switch {
+		case marker != nil && string(marker[1]) == "begin":
+			name, content = string(marker[2]), []byte{}
+		case marker != nil && name == string(marker[2]):
+			found[name] = content
+			name = ""
+		case name != "":
+			content = append(content, line...)
+		}
+	}
+	return found
+}
+
+// mergeRegions fills the user regions of generated with the content of the
+// regions of the same name in existing.
+func mergeRegions(generated, existing []byte) []byte {
+	user := regions(existing)
+	if len(user) == 0 {
+		return generated
+	}
+
+	var out bytes.Buffer
+	skipping := false
+	for _, line := range splitLines(generated) {
+		marker := regionMarker.FindSubmatch(line)
+		switch {
+		case marker != nil && string(marker[1]) == "begin":
+			out.Write(line)
+			if content, ok := user[string(marker[2])]; ok {
+				out.Write(content)
+				skipping = true
+			}
+		case marker != nil:
+			out.Write(line)
+			skipping = false
+		case !skipping:
+			out.Write(line)
+		}
+	}
+	return out.Bytes()
+}
+
+// stripRegions removes the content of every user region, keeping the
+// markers.
+func stripRegions(data []byte) []byte {
+	var out bytes.Buffer
+	inside := false
+	for _, line := range splitLines(data) {
+		marker := regionMarker.FindSubmatch(line)
+		if marker != nil {
+			inside = string(marker[1]) == "begin"
+		}
+		if marker != nil || !inside {
+			out.Write(line)
+		}
+	}
+	return out.Bytes()
+}
+
+// splitLines splits data after each newline, keeping the newlines.
+func splitLines(data []byte) [][]byte {
+	return bytes.SplitAfter(data, []byte("\n"))
+}
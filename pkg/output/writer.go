@@ -0,0 +1,206 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Writer writes generated files under a root directory and records them in
+// the manifest, so a later run can tell generated content from human edits.
+type Writer struct {
+	root      string
+	force     bool
+	fs        fileSystem
+	dryRun    *memFS
+	previous  *Manifest
+	manifest  *Manifest
+	conflicts []string
+}
+
+// NewWriter loads the manifest under root. With force set, files edited
+// since they were generated are overwritten instead of reported.
+func NewWriter(root string, force bool) (*Writer, error) {
+	return newWriter(root, force, dirFS(root), nil)
+}
+
+// NewDryRunWriter behaves like NewWriter but keeps everything it writes in
+// memory, so Changes can report what a real run would do.
+func NewDryRunWriter(root string, force bool) (*Writer, error) {
+	mem := newMemFS(dirFS(root))
+	return newWriter(root, force, mem, mem)
+}
+
+func newWriter(root string, force bool, fsys fileSystem, dryRun *memFS) (*Writer, error) {
+	previous, err := loadManifest(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := newManifest()
+	for name, entry := range previous.Files {
+		manifest.Files[name] = entry
+	}
+	return &Writer{root: root, force: force, fs: fsys, dryRun: dryRun, previous: previous, manifest: manifest}, nil
+}
+
+func (w *Writer) Root() string {
+	return w.root
+}
+
+// DryRun reports whether the writer keeps what it writes in memory.
+func (w *Writer) DryRun() bool {
+	return w.dryRun != nil
+}
+
+// ReadFile returns the current content of a file relative to the root, or
+// nil when it does not exist.
+func (w *Writer) ReadFile(name string) ([]byte, error) {
+	data, err := w.fs.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// OwnedKeys returns the keys dkn wrote to a merged file on the previous run.
+func (w *Writer) OwnedKeys(name string) []string {
+	return w.previous.Files[filepath.ToSlash(name)].Keys
+}
+
+// WriteFile writes a file dkn owns outright. The content of user regions
+// in the existing file is carried over. A file changed outside its user
+// regions since it was generated is left alone and reported by Commit,
+// unless the writer was created with force.
+func (w *Writer) WriteFile(name string, data []byte) error {
+	existing, err := w.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		data = mergeRegions(data, existing)
+		if !w.force && !w.owns(name, existing, data) {
+			w.conflicts = append(w.conflicts, filepath.ToSlash(name))
+			return nil
+		}
+	}
+	return w.write(name, existing, data, Entry{Checksum: Checksum(data)})
+}
+
+// WriteMerged writes a file whose generated keys are merged with keys a
+// human added, such as tfvars. The caller does the merging; keys records
+// which ones dkn owns. Merged files are never reported as conflicts.
+func (w *Writer) WriteMerged(name string, data []byte, keys []string) error {
+	existing, err := w.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return w.write(name, existing, data, Entry{Checksum: Checksum(data), Keys: keys})
+}
+
+// Files lists the generated files recorded under dir, in path order.
+func (w *Writer) Files(dir string) []string {
+	prefix := filepath.ToSlash(dir) + "/"
+	var files []string
+	for name := range w.manifest.Files {
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Remove deletes a generated file that is no longer produced and drops it
+// from the manifest. Like WriteFile, it leaves a file that was edited since
+// it was generated for Commit to report, unless the writer forces. It
+// reports whether the file is gone.
+func (w *Writer) Remove(name string) (bool, error) {
+	key := filepath.ToSlash(name)
+	existing, err := w.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+
+	if existing != nil && !w.force && Checksum(existing) != w.previous.Files[key].Checksum {
+		w.conflicts = append(w.conflicts, key)
+		return false, nil
+	}
+
+	delete(w.manifest.Files, key)
+	if existing == nil {
+		return true, nil
+	}
+	return true, w.fs.Remove(name)
+}
+
+// owns reports whether the existing file is still exactly what dkn last
+// generated. A file missing from the manifest is adopted only when it
+// already matches the new content.
+func (w *Writer) owns(name string, existing, data []byte) bool {
+	entry, ok := w.previous.Files[filepath.ToSlash(name)]
+	if !ok {
+		return Checksum(existing) == Checksum(data)
+	}
+	return Checksum(existing) == entry.Checksum
+}
+
+func (w *Writer) write(name string, existing, data []byte, entry Entry) error {
+	w.manifest.Files[filepath.ToSlash(name)] = entry
+	if existing != nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return w.fs.WriteFile(name, data)
+}
+
+// ConflictError lists the generated files that were edited by hand and so
+// were neither overwritten nor removed.
+type ConflictError struct {
+	Paths []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("refusing to overwrite or remove %d file(s) edited since they were generated (use --force to overwrite):\n  %s",
+		len(e.Paths), strings.Join(e.Paths, "\n  "))
+}
+
+// Commit saves the manifest and reports the files that were not written
+// because they had been edited as a *ConflictError.
+func (w *Writer) Commit() error {
+	if err := w.manifest.save(w.fs); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if len(w.conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(w.conflicts)
+	return &ConflictError{Paths: w.conflicts}
+}
+
+// Changes lists the generated files a dry run would create, modify or
+// remove. The manifest itself is left out. It is empty for a writer that
+// writes to disk.
+func (w *Writer) Changes() ([]Change, error) {
+	if w.dryRun == nil {
+		return nil, nil
+	}
+
+	changes, err := w.dryRun.changes()
+	if err != nil {
+		return nil, err
+	}
+
+	var generated []Change
+	for _, change := range changes {
+		if change.Path != ManifestPath {
+			generated = append(generated, change)
+		}
+	}
+	return generated, nil
+}
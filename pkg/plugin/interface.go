@@ -2,28 +2,53 @@ package plugin
 
 import (
 	"context"
-	"path/filepath"
-	"strings"
+	"sort"
+
+	"github.com/dknathalage/dkn/pkg/config"
+	"github.com/dknathalage/dkn/pkg/output"
 )
 
 type Plugin interface {
 	Name() string
-	ConfigFile() string
-	Generate(ctx context.Context, configPath string, outputDir string) error
+	// Kinds lists the document kinds the plugin consumes.
+	Kinds() []string
+	// Generate writes its files through out, with paths relative to
+	// out.Root().
+	Generate(ctx context.Context, resources []*config.Resource, out *output.Writer) error
+}
+
+// Pruner is implemented by plugins that can remove the generated files
+// their documents no longer produce, such as those of a deleted component.
+type Pruner interface {
+	Plugin
+	Prune(ctx context.Context, resources []*config.Resource, out *output.Writer) error
+}
+
+// SchemaProvider is implemented by plugins that can describe their kinds.
+// KindTypes maps each kind to a value of the Go type its documents decode
+// into.
+type SchemaProvider interface {
+	Plugin
+	KindTypes() map[string]interface{}
 }
 
 type Registry struct {
 	plugins map[string]Plugin
+	kinds   map[string][]string
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
 		plugins: make(map[string]Plugin),
+		kinds:   make(map[string][]string),
 	}
 }
 
 func (r *Registry) Register(plugin Plugin) {
 	r.plugins[plugin.Name()] = plugin
+	for _, kind := range plugin.Kinds() {
+		r.kinds[kind] = append(r.kinds[kind], plugin.Name())
+	}
 }
 
 func (r *Registry) Get(name string) (Plugin, bool) {
@@ -35,32 +60,103 @@ func (r *Registry) All() map[string]Plugin {
 	return r.plugins
 }
 
-func (r *Registry) FindByConfigFile(filename string) (Plugin, bool) {
-	for _, plugin := range r.plugins {
-		configPattern := plugin.ConfigFile()
-		
-		// Handle exact matches (for terraform/terraform.yaml)
-		if configPattern == filename {
-			return plugin, true
+// Names returns the registered plugin names in a stable order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForKind returns the plugins that handle documents of the given kind.
+func (r *Registry) ForKind(kind string) []Plugin {
+	var plugins []Plugin
+	for _, name := range r.kinds[kind] {
+		plugins = append(plugins, r.plugins[name])
+	}
+	return plugins
+}
+
+// Dispatch groups resources by the plugins that handle their kind. A
+// resource is handed to every plugin that declares its kind; resources no
+// plugin handles are returned separately.
+func (r *Registry) Dispatch(resources []*config.Resource) (map[string][]*config.Resource, []*config.Resource) {
+	dispatched := make(map[string][]*config.Resource)
+	var unhandled []*config.Resource
+	for _, resource := range resources {
+		plugins := r.ForKind(resource.Kind)
+		if len(plugins) == 0 {
+			unhandled = append(unhandled, resource)
+			continue
 		}
-		
-		// Handle glob patterns
-		if strings.Contains(configPattern, "*") {
-			matched, err := filepath.Match(configPattern, filename)
-			if err == nil && matched {
-				return plugin, true
-			}
-			
-			// Also try with relative path patterns for multi-level wildcards
-			// e.g., deploy/*/*.yaml should match deploy/terraform/postgres.yaml
-			if strings.Contains(configPattern, "/*/") {
-				// For patterns like "deploy/*/*.yaml", use a simple contains check for now
-				basePath := strings.Split(configPattern, "/*")[0] // "deploy"
-				if strings.HasPrefix(filename, basePath) && strings.HasSuffix(filename, ".yaml") {
-					return plugin, true
-				}
+		for _, plugin := range plugins {
+			dispatched[plugin.Name()] = append(dispatched[plugin.Name()], resource)
+		}
+	}
+	return dispatched, unhandled
+}
+
+// KindTypes merges the kind types of every plugin that provides them.
+func (r *Registry) KindTypes() map[string]interface{} {
+	types := make(map[string]interface{})
+	for _, name := range r.Names() {
+		if provider, ok := r.plugins[name].(SchemaProvider); ok {
+			for kind, v := range provider.KindTypes() {
+				types[kind] = v
 			}
 		}
 	}
-	return nil, false
-}
\ No newline at end of file
+	return types
+}
+
+func (r *Registry) Validators() []Validator {
+	var validators []Validator
+	for _, name := range r.Names() {
+		if v, ok := r.plugins[name].(Validator); ok {
+			validators = append(validators, v)
+		}
+	}
+	return validators
+}
+
+func (r *Registry) Planners() []Planner {
+	var planners []Planner
+	for _, name := range r.Names() {
+		if p, ok := r.plugins[name].(Planner); ok {
+			planners = append(planners, p)
+		}
+	}
+	return planners
+}
+
+func (r *Registry) Appliers() []Applier {
+	var appliers []Applier
+	for _, name := range r.Names() {
+		if a, ok := r.plugins[name].(Applier); ok {
+			appliers = append(appliers, a)
+		}
+	}
+	return appliers
+}
+
+func (r *Registry) Destroyers() []Destroyer {
+	var destroyers []Destroyer
+	for _, name := range r.Names() {
+		if d, ok := r.plugins[name].(Destroyer); ok {
+			destroyers = append(destroyers, d)
+		}
+	}
+	return destroyers
+}
+
+func (r *Registry) Cleaners() []Cleaner {
+	var cleaners []Cleaner
+	for _, name := range r.Names() {
+		if c, ok := r.plugins[name].(Cleaner); ok {
+			cleaners = append(cleaners, c)
+		}
+	}
+	return cleaners
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ErrInterrupted and ErrTerminated are the causes of a context cancelled
+// by SIGINT and SIGTERM.
+var (
+	ErrInterrupted = errors.New("interrupted")
+	ErrTerminated  = errors.New("terminated")
+)
+
+type killKey struct{}
+
+// killGrace is how long dkn waits for killed operations to return after a
+// second signal before exiting regardless.
+const killGrace = time.Second
+
+// Interruptible returns a context that is cancelled on the first SIGINT or
+// SIGTERM, so running operations can stop gracefully. A second signal
+// closes the channel returned by Killed, asking them to stop immediately,
+// and exits the process if it has not stopped shortly after. stop releases
+// the signal handler.
+func Interruptible(parent context.Context) (ctx context.Context, stop func()) {
+	killed := make(chan struct{})
+	ctx, cancel := context.WithCancelCause(context.WithValue(parent, killKey{}, (<-chan struct{})(killed)))
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-signals:
+		case <-done:
+			return
+		}
+		fmt.Fprintln(os.Stderr, "\n⚠️  Interrupted, waiting for running commands to stop. Interrupt again to kill them")
+		if sig == syscall.SIGTERM {
+			cancel(ErrTerminated)
+		} else {
+			cancel(ErrInterrupted)
+		}
+
+		select {
+		case sig = <-signals:
+			close(killed)
+		case <-done:
+			return
+		}
+
+		// Operations blocked outside a killed command would otherwise
+		// keep dkn running.
+		select {
+		case <-time.After(killGrace):
+			fmt.Fprintln(os.Stderr, "⚠️  Killed")
+			os.Exit(exitCode(sig))
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel(nil)
+	}
+}
+
+// Killed returns a channel that is closed when running operations should
+// be stopped immediately. It is nil, never closed, for a context that did
+// not come from Interruptible.
+func Killed(ctx context.Context) <-chan struct{} {
+	killed, _ := ctx.Value(killKey{}).(<-chan struct{})
+	return killed
+}
+
+// exitCode is the status a shell reports for a process killed by sig.
+func exitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/dknathalage/dkn/pkg/config"
+)
+
+// Options identifies what a lifecycle operation should act on. An empty
+// Component means every component the plugin manages.
+type Options struct {
+	DeployPath  string
+	OutputDir   string
+	Component   string
+	Environment string
+	// Parallelism caps how many independent components are operated on at
+	// once; zero means one at a time.
+	Parallelism int
+	// UsePlan applies the plans saved by the last plan instead of planning
+	// again.
+	UsePlan bool
+	// Timeout bounds the time spent on each component; zero means no
+	// limit.
+	Timeout time.Duration
+	// KillAfter bounds how long an interrupted command may take to stop
+	// before it is killed; zero means the plugin's default.
+	KillAfter time.Duration
+	// AutoApprove applies changes without asking.
+	AutoApprove bool
+	// AllowCI lets destructive operations run in CI.
+	AllowCI bool
+	// Confirm answers a typed confirmation up front, for use without a
+	// terminal.
+	Confirm string
+}
+
+// Validator checks the documents dispatched to the plugin. Problems tied
+// to a document position should be returned as a config.ErrorList.
+type Validator interface {
+	Plugin
+	Validate(ctx context.Context, resources []*config.Resource) error
+}
+
+type Planner interface {
+	Plugin
+	Plan(ctx context.Context, opts Options) error
+}
+
+type Applier interface {
+	Plugin
+	Apply(ctx context.Context, opts Options) error
+}
+
+type Destroyer interface {
+	Plugin
+	Destroy(ctx context.Context, opts Options) error
+}
+
+type Cleaner interface {
+	Plugin
+	Clean(ctx context.Context, opts Options) error
+}
@@ -1,61 +1,283 @@
 package terraform
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dknathalage/dkn/pkg/plugin"
 )
 
-func (p *TerraformPlugin) Apply(ctx context.Context, deployPath string, outputDir string, component string, environment string) error {
-	_, err := LoadConfig(deployPath)
+// target is a generated component directory ready to be operated on in a
+// single environment.
+type target struct {
+	// Binary is the executable the project runs Terraform with.
+	Binary      string
+	Component   string
+	Environment string
+	Dir         string
+	InitArgs    []string
+	// WorkspaceArgs select the environment's workspace after init, for
+	// components using the workspace strategy.
+	WorkspaceArgs []string
+	// Env selects the state on workspace-based backends.
+	Env       []string
+	DependsOn []string
+	// KillAfter bounds how long interrupted commands may take to stop.
+	KillAfter time.Duration
+	// Output receives Terraform's output; nil means the terminal.
+	Output io.Writer
+}
+
+func (t target) stdout() io.Writer {
+	if t.Output != nil {
+		return t.Output
+	}
+	return os.Stdout
+}
+
+func (t target) stderr() io.Writer {
+	if t.Output != nil {
+		return t.Output
+	}
+	return os.Stderr
+}
+
+// Apply plans, approves and applies components in dependency order.
+// Independent components run concurrently up to opts.Parallelism, with
+// their output prefixed by the component name. With opts.UsePlan the plans
+// saved by dkn plan are applied instead of planning again.
+func (p *TerraformPlugin) Apply(ctx context.Context, opts plugin.Options) error {
+	config, err := LoadConfig(opts.DeployPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	targets, err := p.targets(ctx, config, opts)
+	if err != nil {
+		return err
+	}
+
+	approval, err := newApproval(config, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.UsePlan {
+		for _, t := range targets {
+			if _, err := os.Stat(t.planPath()); err != nil {
+				return fmt.Errorf("no saved plan for %s in %s environment, run 'dkn plan' first", t.Component, t.Environment)
+			}
+		}
+	}
+
+	if opts.Parallelism > 1 {
+		var mu sync.Mutex
+		for i := range targets {
+			targets[i].Output = &prefixWriter{prefix: "[" + targets[i].Component + "] ", out: os.Stdout, mu: &mu}
+		}
+	}
+
+	return runOrdered(ctx, targets, opts.Parallelism, func(t target) error {
+		fmt.Printf("🚀 Applying component: %s\n", t.Component)
+		timer := newTimer(t, opts.Timeout)
+
+		var summary planSummary
+		err := timer.run(ctx, func(ctx context.Context) error {
+			if err := p.terraformInit(ctx, t); err != nil {
+				return fmt.Errorf("terraform init failed for %s: %w", t.Component, err)
+			}
+
+			if !opts.UsePlan {
+				if err := p.terraformPlan(ctx, t); err != nil {
+					return fmt.Errorf("terraform plan failed for %s: %w", t.Component, err)
+				}
+			}
+
+			var err error
+			if summary, err = p.showPlan(ctx, t); err != nil {
+				return fmt.Errorf("failed to read plan for %s: %w", t.Component, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := approval.approve(ctx, t, summary); err != nil {
+			return err
+		}
+
+		err = timer.run(ctx, func(ctx context.Context) error {
+			return p.terraformApplyPlan(ctx, t)
+		})
+		if err != nil {
+			return fmt.Errorf("terraform apply failed for %s: %w", t.Component, err)
+		}
+
+		fmt.Printf("✅ Applied Terraform changes for %s in %s environment\n", t.Component, t.Environment)
+		return nil
+	})
+}
+
+// targets resolves the components selected by opts into generated
+// directories, in dependency order. Components that are not deployed to the requested
+// environment are skipped unless they were asked for by name. It fails
+// early when the installed binary does not satisfy the required version.
+func (p *TerraformPlugin) targets(ctx context.Context, config *Config, opts plugin.Options) ([]target, error) {
+	if err := p.checkVersion(ctx, config, opts.OutputDir); err != nil {
+		return nil, err
+	}
+
+	components, err := config.SelectComponents(opts.Component)
+	if err != nil {
+		return nil, err
+	}
+
 	org, repo, err := p.getOrgAndRepo()
 	if err != nil {
-		return fmt.Errorf("failed to get org/repo: %w", err)
+		return nil, fmt.Errorf("failed to get org/repo: %w", err)
 	}
 
-	componentDir := filepath.Join(outputDir, "terraform", component)
-	if _, err := os.Stat(componentDir); os.IsNotExist(err) {
-		return fmt.Errorf("component directory %s does not exist. Run 'gen' command first", componentDir)
+	var targets []target
+	for _, component := range components {
+		name := component.Metadata.Name
+		if !contains(config.EnvironmentsFor(component), opts.Environment) {
+			if opts.Component != "" {
+				return nil, fmt.Errorf("component %s is not deployed to environment %s", name, opts.Environment)
+			}
+			continue
+		}
+
+		componentDir := filepath.Join(opts.OutputDir, "terraform", name)
+		if _, err := os.Stat(componentDir); os.IsNotExist(err) {
+			return nil, fmt.Errorf("component directory %s does not exist. Run 'gen' command first", componentDir)
+		}
+
+		targets = append(targets, target{
+			Binary:        config.Binary(),
+			Component:     name,
+			Environment:   opts.Environment,
+			Dir:           componentDir,
+			InitArgs:      config.InitArgs(component, org, repo, opts.Environment),
+			WorkspaceArgs: config.SelectWorkspaceArgs(component, opts.Environment),
+			Env:           config.StateEnv(component, org, repo, opts.Environment),
+			DependsOn:     component.Dependencies(),
+			KillAfter:     opts.KillAfter,
+		})
 	}
+	return targets, nil
+}
 
-	if err := p.terraformInit(componentDir, org, repo, component, environment); err != nil {
-		return fmt.Errorf("terraform init failed: %w", err)
+// run runs terraform with args in the directory of t, sending its output
+// wherever the output of t goes.
+func (p *TerraformPlugin) run(ctx context.Context, t target, args ...string) error {
+	return p.exec.Run(ctx, Command{Binary: t.Binary, Args: args, Dir: t.Dir, Env: t.Env, Stdout: t.stdout(), Stderr: t.stderr(), KillAfter: t.KillAfter})
+}
+
+// terraformInit initialises t and, with the workspace strategy, selects the
+// environment's workspace.
+func (p *TerraformPlugin) terraformInit(ctx context.Context, t target) error {
+	fmt.Printf("🔄 Initializing Terraform for %s in %s environment...\n", t.Component, t.Environment)
+	if err := p.run(ctx, t, t.InitArgs...); err != nil {
+		return err
 	}
+	if t.WorkspaceArgs == nil {
+		return nil
+	}
+	return p.run(ctx, t, t.WorkspaceArgs...)
+}
 
-	if err := p.terraformApply(componentDir, environment); err != nil {
-		return fmt.Errorf("terraform apply failed: %w", err)
+// terraformApplyPlan applies a saved plan, which Terraform does without
+// asking. The plan is removed afterwards since it cannot be applied twice.
+func (p *TerraformPlugin) terraformApplyPlan(ctx context.Context, t target) error {
+	fmt.Printf("🚀 Applying Terraform plan for %s in %s environment...\n", t.Component, t.Environment)
+	if err := p.run(ctx, t, "apply", t.planFile()); err != nil {
+		return err
 	}
+	return os.Remove(t.planPath())
+}
 
-	fmt.Printf("✅ Applied Terraform changes for %s in %s environment\n", component, environment)
-	return nil
+// timer bounds the Terraform commands of a component by a timeout shared
+// between its runs, so time spent waiting for approval does not count.
+type timer struct {
+	component string
+	limit     time.Duration
+	used      time.Duration
 }
 
-func (p *TerraformPlugin) terraformInit(workDir, org, repo, component, environment string) error {
-	prefix := fmt.Sprintf("%s/%s/%s/%s", org, repo, component, environment)
-	
-	cmd := exec.Command("terraform", "init", "-reconfigure", fmt.Sprintf("-backend-config=prefix=%s", prefix))
-	cmd.Dir = workDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func newTimer(t target, limit time.Duration) *timer {
+	return &timer{component: t.Component, limit: limit}
+}
 
-	fmt.Printf("🔄 Initializing Terraform for %s in %s environment...\n", component, environment)
-	return cmd.Run()
+func (tm *timer) run(ctx context.Context, fn func(context.Context) error) error {
+	if tm.limit <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, tm.limit-tm.used, fmt.Errorf("%s timed out after %s", tm.component, tm.limit))
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	tm.used += time.Since(start)
+	return err
 }
 
-func (p *TerraformPlugin) terraformApply(workDir, environment string) error {
-	tfvarsFile := filepath.Join("tfvars", environment+".tfvars")
-	
-	cmd := exec.Command("terraform", "apply", fmt.Sprintf("-var-file=%s", tfvarsFile), "-auto-approve")
-	cmd.Dir = workDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func varFileArg(environment string) string {
+	return fmt.Sprintf("-var-file=%s", filepath.Join("tfvars", environment+".tfvars"))
+}
 
-	fmt.Printf("🚀 Applying Terraform changes for %s environment...\n", environment)
-	return cmd.Run()
-}
\ No newline at end of file
+// prefixWriter prefixes every line with the component it came from, so
+// the output of components applied in parallel stays readable. Writers
+// sharing a mutex never interleave within a line.
+type prefixWriter struct {
+	prefix  string
+	out     io.Writer
+	mu      *sync.Mutex
+	partial []byte
+}
+
+func (w *prefixWriter) Write(data []byte) (int, error) {
+	w.partial = append(w.partial, data...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(data), nil
+		}
+		w.mu.Lock()
+		_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, w.partial[:i+1])
+		w.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// Flush writes out a last line that did not end in a newline, so it is
+// neither lost nor joined to the next command's output.
+func (w *prefixWriter) Flush() error {
+	if len(w.partial) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	_, err := fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.partial)
+	w.mu.Unlock()
+	w.partial = nil
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package terraform
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type backendSetting struct {
+	Key   string
+	Value string
+}
+
+// stateBackend describes how a backend type locates the state of a
+// component in an environment. Most take the state path in one setting;
+// remote and cloud select a workspace instead.
+type stateBackend struct {
+	// key is the setting that receives the state location.
+	key string
+	// location maps the state path to the value of key. base is the value
+	// of key configured by the user, if any.
+	location func(base, statePath string) string
+	// base means a configured key is kept as the base of the location
+	// rather than being dkn's to set.
+	base bool
+	// located are optional base settings that get a location of their own
+	// when configured, so environments do not share them.
+	located []string
+	// required are the settings the backend cannot work without.
+	required []string
+	// workspaces means state is selected through TF_WORKSPACE.
+	workspaces bool
+}
+
+// DefaultLocalStateDir holds local state when the local backend has no
+// path of its own. It sits in the component directory, where the generated
+// .gitignore keeps it out of version control.
+const DefaultLocalStateDir = ".terraform-state"
+
+// StateBackendFor returns the backend that holds a component's state. A
+// component without a backend uses the local backend, so each environment
+// still gets a state file of its own instead of sharing terraform.tfstate.
+func (c *Config) StateBackendFor(component TerraformResource) BackendConfig {
+	backend := c.BackendFor(component)
+	if backend.Type == "" {
+		backend.Type = "local"
+	}
+	return backend
+}
+
+var stateBackends = map[string]stateBackend{
+	"gcs":     {key: "prefix", location: statePrefix, required: []string{"bucket"}},
+	"s3":      {key: "key", location: objectKey, required: []string{"bucket", "region"}},
+	"azurerm": {key: "key", location: objectKey, required: []string{"storage_account_name", "container_name"}},
+	"local":   {key: "path", location: localPath, base: true},
+	"http":    {key: "address", location: addressPath, base: true, located: []string{"lock_address", "unlock_address"}, required: []string{"address"}},
+	"remote":  {workspaces: true, required: []string{"organization"}},
+	"cloud":   {workspaces: true, required: []string{"organization"}},
+}
+
+func statePrefix(_, statePath string) string {
+	return statePath
+}
+
+func objectKey(_, statePath string) string {
+	return statePath + "/terraform.tfstate"
+}
+
+// localPath treats a configured path as the directory to keep state in.
+func localPath(base, statePath string) string {
+	if base == "" {
+		base = DefaultLocalStateDir
+	}
+	return path.Join(base, statePath+".tfstate")
+}
+
+func addressPath(base, statePath string) string {
+	return strings.TrimSuffix(base, "/") + "/" + statePath
+}
+
+// locates reports whether key receives the state location.
+func (b stateBackend) locates(key string) bool {
+	return key == b.key || contains(b.located, key)
+}
+
+// derived returns the settings dkn sets itself and the user must not.
+func (b stateBackend) derived() []string {
+	switch {
+	case b.workspaces:
+		return []string{"workspaces"}
+	case !b.base:
+		return []string{b.key}
+	}
+	return nil
+}
+
+// InitArgs returns the arguments for terraform init of a component in one
+// environment. Apply and the generated Taskfiles both use it so they always
+// address the same state.
+//
+// With the workspace strategy every environment shares the same arguments,
+// so there is nothing to reconfigure between them.
+func (c *Config) InitArgs(component TerraformResource, org, repo, environment string) []string {
+	args := []string{"init", "-reconfigure"}
+	if component.UsesWorkspaces() {
+		args = []string{"init"}
+	}
+	for _, setting := range c.stateSettings(component, org, repo, environment) {
+		args = append(args, fmt.Sprintf("-backend-config=%s=%s", setting.Key, setting.Value))
+	}
+	return args
+}
+
+// stateSettings returns the backend settings that locate a component's
+// state in an environment on top of its backend.tf: the state location and
+// any other located settings, then the environment's overrides.
+// With the workspace strategy the location leaves out the environment,
+// which selects a workspace instead, and environments cannot override it.
+func (c *Config) stateSettings(component TerraformResource, org, repo, environment string) []backendSetting {
+	backend := c.StateBackendFor(component)
+	kind := stateBackends[backend.Type]
+	env, _ := c.Environment(environment)
+	statePath := c.StatePrefix(org, repo, component.Metadata.Name, environment)
+	if component.UsesWorkspaces() {
+		env = Environment{}
+		statePath = c.componentStatePath(component, org, repo)
+	}
+
+	base := func(key string) string {
+		if override, ok := env.Spec.Backend.Config[key]; ok {
+			return override
+		}
+		return backend.Config[key]
+	}
+
+	var settings []backendSetting
+	if kind.key != "" {
+		settings = append(settings, backendSetting{kind.key, kind.location(base(kind.key), statePath)})
+	}
+	for _, key := range kind.located {
+		if value := base(key); value != "" {
+			settings = append(settings, backendSetting{key, kind.location(value, statePath)})
+		}
+	}
+	for _, key := range sortedKeys(env.Spec.Backend.Config) {
+		if !kind.locates(key) {
+			settings = append(settings, backendSetting{key, env.Spec.Backend.Config[key]})
+		}
+	}
+	return settings
+}
+
+// StateConfig returns the complete backend configuration of a component's
+// state in an environment, as a terraform_remote_state data source needs
+// it. Workspace-based backends also need the workspace from Workspace.
+func (c *Config) StateConfig(component TerraformResource, org, repo, environment string) map[string]string {
+	config := make(map[string]string)
+	for key, value := range c.StateBackendFor(component).Config {
+		config[key] = value
+	}
+	for _, setting := range c.stateSettings(component, org, repo, environment) {
+		config[setting.Key] = setting.Value
+	}
+	return config
+}
+
+// Workspace returns the workspace holding a component's state in an
+// environment on a remote or cloud backend, or "" for other backends:
+// the component's workspace prefix followed by the environment.
+func (c *Config) Workspace(component TerraformResource, org, repo, environment string) string {
+	if !stateBackends[c.BackendFor(component).Type].workspaces {
+		return ""
+	}
+	return c.WorkspacePrefix(component, org, repo) + "-" + environment
+}
+
+var workspaceUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// WorkspacePrefix names a component's workspaces after its state prefix
+// without the environment, in the characters workspace names allow.
+func (c *Config) WorkspacePrefix(component TerraformResource, org, repo string) string {
+	prefix := c.componentStatePath(component, org, repo)
+	return strings.Trim(workspaceUnsafe.ReplaceAllString(prefix, "-"), "-")
+}
+
+// componentStatePath is the state prefix with the environment left out,
+// along with the separators around it.
+func (c *Config) componentStatePath(component TerraformResource, org, repo string) string {
+	prefix := path.Clean(c.StatePrefix(org, repo, component.Metadata.Name, ""))
+	return strings.Trim(prefix, "/-_.")
+}
+
+// SelectWorkspaceArgs returns the arguments that switch a component using
+// the workspace strategy to the environment's workspace, creating it on
+// first use, or nil for other components.
+func (c *Config) SelectWorkspaceArgs(component TerraformResource, environment string) []string {
+	if !component.UsesWorkspaces() {
+		return nil
+	}
+	return []string{"workspace", "select", "-or-create", environment}
+}
+
+// StateEnv returns the environment variables Terraform needs to find a
+// component's state. The remote backend selects workspaces by the name
+// after its prefix, the cloud block by their full name.
+func (c *Config) StateEnv(component TerraformResource, org, repo, environment string) []string {
+	switch c.BackendFor(component).Type {
+	case "remote":
+		return []string{"TF_WORKSPACE=" + environment}
+	case "cloud":
+		return []string{"TF_WORKSPACE=" + c.Workspace(component, org, repo, environment)}
+	}
+	return nil
+}
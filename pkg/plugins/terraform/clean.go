@@ -0,0 +1,46 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dknathalage/dkn/pkg/output"
+	"github.com/dknathalage/dkn/pkg/plugin"
+)
+
+// Clean removes the local .terraform working directories created by init.
+// Without a component it also prunes generated files the config no longer
+// produces.
+func (p *TerraformPlugin) Clean(ctx context.Context, opts plugin.Options) error {
+	config, err := LoadConfig(opts.DeployPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	components, err := config.SelectComponents(opts.Component)
+	if err != nil {
+		return err
+	}
+
+	for _, component := range components {
+		workDir := filepath.Join(opts.OutputDir, "terraform", component.Metadata.Name, ".terraform")
+		if err := os.RemoveAll(workDir); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", workDir, err)
+		}
+	}
+
+	if opts.Component != "" {
+		return nil
+	}
+
+	out, err := output.NewWriter(opts.OutputDir, false)
+	if err != nil {
+		return err
+	}
+	if err := p.prune(config, out); err != nil {
+		return err
+	}
+	return out.Commit()
+}
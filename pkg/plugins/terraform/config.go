@@ -1,47 +1,220 @@
 package terraform
 
 import (
-	"os"
-	"path/filepath"
-	"gopkg.in/yaml.v3"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dknathalage/dkn/pkg/config"
+)
+
+const (
+	KindProject     = "Project"
+	KindEnvironment = "Environment"
+	KindTerraform   = "Terraform"
+)
+
+// DefaultStatePrefix is used when the project does not declare its own
+// naming convention for state locations.
+const DefaultStatePrefix = "{org}/{repo}/{component}/{environment}"
+
+// State strategies a component can set in stateStrategy.
+const (
+	StateStrategyPrefix    = "prefix"
+	StateStrategyWorkspace = "workspace"
 )
 
-type Document struct {
+// DefaultBinary runs when the project does not pick a distribution.
+const DefaultBinary = "terraform"
+
+// binaries are the distributions a project may pick.
+var binaries = []string{"terraform", "tofu"}
+
+// Project holds project-wide defaults. Components override the backend and
+// providers through their own spec.
+type Project struct {
 	Kind     string      `yaml:"kind"`
 	Metadata Metadata    `yaml:"metadata"`
-	Spec     interface{} `yaml:"spec"`
+	Spec     ProjectSpec `yaml:"spec"`
+
+	resource *config.Resource
+}
+
+type ProjectSpec struct {
+	// Binary is the distribution dkn runs, terraform (the default) or tofu.
+	Binary string `yaml:"binary"`
+	// RequiredVersion is a version constraint such as ">= 1.6, < 2.0". It
+	// is checked against the installed binary before any lifecycle
+	// operation and written to required_version.
+	RequiredVersion string        `yaml:"requiredVersion"`
+	Backend         BackendConfig `yaml:"backend"`
+	Providers       []Provider    `yaml:"providers"`
+	Naming          Naming        `yaml:"naming"`
+}
+
+type Naming struct {
+	// StatePrefix may use the {project}, {org}, {repo}, {component} and
+	// {environment} placeholders.
+	StatePrefix string `yaml:"statePrefix"`
 }
 
 type Environment struct {
-	Kind     string   `yaml:"kind"`
-	Metadata Metadata `yaml:"metadata"`
+	Kind     string          `yaml:"kind"`
+	Metadata Metadata        `yaml:"metadata"`
+	Spec     EnvironmentSpec `yaml:"spec"`
+
+	resource *config.Resource
+}
+
+type EnvironmentSpec struct {
+	// Order places the environment in the promotion sequence; lower values
+	// are promoted first.
+	Order     int  `yaml:"order"`
+	Protected bool `yaml:"protected"`
+	// RequireApproval makes every apply to the environment ask for its
+	// name to be typed, even with --auto-approve.
+	RequireApproval bool `yaml:"requireApproval"`
+	// Variables are written into the tfvars of every component deployed
+	// to the environment.
+	Variables map[string]EnvironmentVariable `yaml:"variables"`
+	// Backend settings are passed as -backend-config at init time and
+	// override the project or component backend for this environment.
+	Backend EnvironmentBackend `yaml:"backend"`
+	// Providers sets provider attributes such as project or region per
+	// provider name.
+	Providers map[string]map[string]string `yaml:"providers"`
+}
+
+type EnvironmentVariable struct {
+	Type        string      `yaml:"type"`
+	Description string      `yaml:"description"`
+	Value       interface{} `yaml:"value"`
+}
+
+type EnvironmentBackend struct {
+	Config map[string]string `yaml:"config"`
 }
 
 type TerraformResource struct {
 	Kind     string        `yaml:"kind"`
 	Metadata Metadata      `yaml:"metadata"`
 	Spec     TerraformSpec `yaml:"spec"`
+
+	resource *config.Resource
 }
 
 type TerraformSpec struct {
-	Environments    []string      `yaml:"environments"`
-	EnvironmentRefs []string      `yaml:"environmentRefs"`
-	Backend         BackendConfig `yaml:"backend"`
-	Providers       []Provider    `yaml:"providers"`
+	Environments    []string `yaml:"environments"`
+	EnvironmentRefs []string `yaml:"environmentRefs"`
+	// EnvironmentSelector picks environments by label when none are listed.
+	EnvironmentSelector Selector `yaml:"environmentSelector"`
+	// DependsOn names components that must be applied before this one and
+	// destroyed after it.
+	DependsOn []string `yaml:"dependsOn"`
+	// RemoteStates reads the outputs of other components in the same
+	// environment, as data.terraform_remote_state.<component>.outputs.
+	RemoteStates []RemoteState `yaml:"remoteStates"`
+	// StateStrategy is how environments get separate state: "prefix" (the
+	// default) gives each its own state location, "workspace" shares one
+	// location and selects a workspace named after the environment.
+	StateStrategy string        `yaml:"stateStrategy"`
+	Backend       BackendConfig `yaml:"backend"`
+	Providers     []Provider    `yaml:"providers"`
+	Variables     []Variable    `yaml:"variables"`
+	Modules       []Module      `yaml:"modules"`
+	Resources     []Block       `yaml:"resources"`
+}
+
+type RemoteState struct {
+	Component string `yaml:"component"`
+}
+
+// UsesWorkspaces reports whether the component keeps the state of each
+// environment in a workspace.
+func (t TerraformResource) UsesWorkspaces() bool {
+	return t.Spec.StateStrategy == StateStrategyWorkspace
+}
+
+// Dependencies lists the components that must be applied first: those in
+// dependsOn and those whose remote state is read.
+func (t TerraformResource) Dependencies() []string {
+	dependencies := append([]string{}, t.Spec.DependsOn...)
+	for _, state := range t.Spec.RemoteStates {
+		if state.Component != t.Metadata.Name && !contains(dependencies, state.Component) {
+			dependencies = append(dependencies, state.Component)
+		}
+	}
+	return dependencies
+}
+
+// Module is a module call in the generated main.tf. Source is a local path
+// or a registry address; Version only applies to the latter.
+type Module struct {
+	Name    string                 `yaml:"name"`
+	Source  string                 `yaml:"source"`
+	Version string                 `yaml:"version"`
+	Inputs  map[string]interface{} `yaml:"inputs"`
+}
+
+// Block describes a resource, or a nested block inside one. Resources use
+// Type and Name as their labels; nested blocks only use Type.
+type Block struct {
+	Type       string                 `yaml:"type"`
+	Name       string                 `yaml:"name"`
+	Attributes map[string]interface{} `yaml:"attributes"`
+	Blocks     []Block                `yaml:"blocks"`
+}
+
+func (m Module) IsLocal() bool {
+	return strings.HasPrefix(m.Source, "./") || strings.HasPrefix(m.Source, "../")
+}
+
+// Variable is a Terraform input variable declared by a component. Values
+// sets it per environment in the generated tfvars.
+type Variable struct {
+	Name        string                 `yaml:"name"`
+	Type        string                 `yaml:"type"`
+	Description string                 `yaml:"description"`
+	Default     interface{}            `yaml:"default"`
+	Sensitive   bool                   `yaml:"sensitive"`
+	Validation  []VariableValidation   `yaml:"validation"`
+	Values      map[string]interface{} `yaml:"values"`
+
+	// optional variables default to null when no default is given.
+	optional bool
+}
+
+type VariableValidation struct {
+	Condition    string `yaml:"condition"`
+	ErrorMessage string `yaml:"errorMessage"`
+}
+
+type Selector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+func (s Selector) Empty() bool {
+	return len(s.MatchLabels) == 0
+}
+
+func (s Selector) Matches(labels map[string]string) bool {
+	for key, value := range s.MatchLabels {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
 }
 
 type Config struct {
+	Project      *Project
 	Environments []Environment
 	Components   []TerraformResource
 	Backend      BackendConfig
 	Providers    []Provider
 }
 
-type Metadata struct {
-	Name        string            `yaml:"name"`
-	Description string            `yaml:"description"`
-	Labels      map[string]string `yaml:"labels"`
-}
+type Metadata = config.Metadata
 
 type BackendConfig struct {
 	Type   string            `yaml:"type"`
@@ -54,67 +227,210 @@ type Provider struct {
 	Version string `yaml:"version"`
 }
 
-func LoadConfig(deployPath string) (*Config, error) {
-	var environments []Environment
-	var components []TerraformResource
-	
-	// Load environments from deploy/environments/
-	envPath := filepath.Join(deployPath, "environments")
-	if envFiles, err := filepath.Glob(filepath.Join(envPath, "*.yaml")); err == nil {
-		for _, envFile := range envFiles {
-			data, err := os.ReadFile(envFile)
-			if err != nil {
-				continue
+// EnvironmentsFor returns the environments a component is deployed to.
+// Explicitly listed environments win, then the label selector, then every
+// known environment in promotion order.
+func (c *Config) EnvironmentsFor(component TerraformResource) []string {
+	envs := component.Spec.Environments
+	if len(envs) == 0 {
+		envs = component.Spec.EnvironmentRefs
+	}
+	if len(envs) > 0 {
+		return envs
+	}
+
+	for _, env := range c.Environments {
+		if component.Spec.EnvironmentSelector.Matches(env.Metadata.Labels) {
+			envs = append(envs, env.Metadata.Name)
+		}
+	}
+	return envs
+}
+
+// BackendFor returns the component's backend, or the project default.
+func (c *Config) BackendFor(component TerraformResource) BackendConfig {
+	if component.Spec.Backend.Type != "" {
+		return component.Spec.Backend
+	}
+	return c.Backend
+}
+
+// ProvidersFor returns the component's providers, or the project defaults.
+func (c *Config) ProvidersFor(component TerraformResource) []Provider {
+	if len(component.Spec.Providers) > 0 {
+		return component.Spec.Providers
+	}
+	return c.Providers
+}
+
+// Binary returns the executable the project runs Terraform with.
+func (c *Config) Binary() string {
+	if c.Project == nil || c.Project.Spec.Binary == "" {
+		return DefaultBinary
+	}
+	return c.Project.Spec.Binary
+}
+
+func (c *Config) RequiredVersion() string {
+	if c.Project == nil {
+		return ""
+	}
+	return c.Project.Spec.RequiredVersion
+}
+
+func (c *Config) ProjectName() string {
+	if c.Project == nil {
+		return ""
+	}
+	return c.Project.Metadata.Name
+}
+
+// StatePrefix expands the project's naming convention for the state of a
+// component in an environment.
+func (c *Config) StatePrefix(org, repo, component, environment string) string {
+	prefix := DefaultStatePrefix
+	if c.Project != nil && c.Project.Spec.Naming.StatePrefix != "" {
+		prefix = c.Project.Spec.Naming.StatePrefix
+	}
+	return strings.NewReplacer(
+		"{project}", c.ProjectName(),
+		"{org}", org,
+		"{repo}", repo,
+		"{component}", component,
+		"{environment}", environment,
+	).Replace(prefix)
+}
+
+func (c *Config) Environment(name string) (Environment, bool) {
+	for _, env := range c.Environments {
+		if env.Metadata.Name == name {
+			return env, true
+		}
+	}
+	return Environment{}, false
+}
+
+// EnvironmentVariables returns the variables declared by any of the given
+// environments, keyed by name. The first declaration of a name wins.
+func (c *Config) EnvironmentVariables(environments []string) map[string]EnvironmentVariable {
+	variables := make(map[string]EnvironmentVariable)
+	for _, name := range environments {
+		env, _ := c.Environment(name)
+		for key, variable := range env.Spec.Variables {
+			if _, ok := variables[key]; !ok {
+				variables[key] = variable
 			}
-			
-			var env Environment
-			if err := yaml.Unmarshal(data, &env); err == nil && env.Kind == "Environment" {
-				environments = append(environments, env)
+		}
+	}
+	return variables
+}
+
+// ProviderAttributes returns, per provider, the sorted attribute names any
+// of the given environments sets.
+func (c *Config) ProviderAttributes(environments []string) map[string][]string {
+	seen := make(map[string]map[string]bool)
+	for _, name := range environments {
+		env, _ := c.Environment(name)
+		for provider, attributes := range env.Spec.Providers {
+			if seen[provider] == nil {
+				seen[provider] = make(map[string]bool)
+			}
+			for attribute := range attributes {
+				seen[provider][attribute] = true
 			}
 		}
 	}
-	
-	// Load terraform components from deploy/terraform/
-	tfPath := filepath.Join(deployPath, "terraform")
-	if tfFiles, err := filepath.Glob(filepath.Join(tfPath, "*.yaml")); err == nil {
-		for _, tfFile := range tfFiles {
-			data, err := os.ReadFile(tfFile)
-			if err != nil {
-				continue
+
+	result := make(map[string][]string)
+	for provider, attributes := range seen {
+		for attribute := range attributes {
+			result[provider] = append(result[provider], attribute)
+		}
+		sort.Strings(result[provider])
+	}
+	return result
+}
+
+// ProviderVariable names the Terraform variable that carries a provider
+// attribute set per environment.
+func ProviderVariable(provider, attribute string) string {
+	return strings.ReplaceAll(provider+"_"+attribute, "-", "_")
+}
+
+// SelectComponents returns the named component, or every component in
+// dependency order when name is empty.
+func (c *Config) SelectComponents(name string) ([]TerraformResource, error) {
+	if name == "" {
+		return c.Ordered()
+	}
+	if component, ok := c.Component(name); ok {
+		return []TerraformResource{component}, nil
+	}
+	return nil, fmt.Errorf("component %s not found", name)
+}
+
+// LoadConfig reads every document below deployPath and keeps the kinds
+// this plugin understands.
+func LoadConfig(deployPath string) (*Config, error) {
+	resources, err := config.LoadDir(deployPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewConfig(resources)
+}
+
+func NewConfig(resources []*config.Resource) (*Config, error) {
+	config := &Config{}
+
+	for _, resource := range resources {
+		switch resource.Kind {
+		case KindProject:
+			var project Project
+			if err := resource.Decode(&project); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", resource.Location(), err)
 			}
-			
+			project.resource = resource
+			config.Project = &project
+		case KindEnvironment:
+			var env Environment
+			if err := resource.Decode(&env); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", resource.Location(), err)
+			}
+			env.resource = resource
+			config.Environments = append(config.Environments, env)
+		case KindTerraform:
 			var tfResource TerraformResource
-			if err := yaml.Unmarshal(data, &tfResource); err == nil && tfResource.Kind == "Terraform" {
-				components = append(components, tfResource)
+			if err := resource.Decode(&tfResource); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", resource.Location(), err)
+			}
+			tfResource.resource = resource
+			// Components are looked up and ordered by name.
+			if first, ok := config.Component(tfResource.Metadata.Name); ok {
+				return nil, fmt.Errorf("duplicate component %s in %s, first defined in %s", tfResource.Metadata.Name, resource.Location(), first.resource.Location())
 			}
+			config.Components = append(config.Components, tfResource)
 		}
 	}
-	
-	config := &Config{
-		Environments: environments,
-		Components:   components,
-	}
-	
-	// Set defaults for backend and providers if not specified in any component
-	config.Backend = BackendConfig{
-		Type: "gcs",
-		Config: map[string]string{
-			"bucket": "dknathalage-tf-state",
-		},
-	}
-	
-	config.Providers = []Provider{
-		{
-			Name:    "google",
-			Source:  "hashicorp/google",
-			Version: "6.46.0",
-		},
-		{
-			Name:    "google-beta",
-			Source:  "hashicorp/google-beta",
-			Version: "6.46.0",
-		},
-	}
-	
+
+	// Keep environments in promotion order so everything derived from them
+	// is too.
+	sort.SliceStable(config.Environments, func(i, j int) bool {
+		return config.Environments[i].Spec.Order < config.Environments[j].Spec.Order
+	})
+
+	if config.Project != nil {
+		config.Backend = config.Project.Spec.Backend
+		config.Providers = config.Project.Spec.Providers
+	}
+
 	return config, nil
-}
\ No newline at end of file
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dknathalage/dkn/pkg/plugin"
+)
+
+// inCI reports whether dkn runs under a CI system, which by convention
+// sets CI.
+func inCI() bool {
+	value := os.Getenv("CI")
+	return value != "" && value != "false" && value != "0"
+}
+
+// promptLine prints prompt and reads one line from stdin, giving up when
+// ctx is cancelled. It reads a byte at a time and stops reading when it
+// gives up, so nothing meant for a later Terraform prompt is consumed.
+func promptLine(ctx context.Context, prompt string) (string, error) {
+	fmt.Print(prompt)
+	in, abandon, release := openStdin()
+	defer release()
+
+	type answer struct {
+		line string
+		err  error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		var line []byte
+		buf := make([]byte, 1)
+		for {
+			n, err := in.Read(buf)
+			if n == 1 && buf[0] != '\n' {
+				line = append(line, buf[0])
+			}
+			if n == 1 && buf[0] == '\n' || err == io.EOF {
+				answers <- answer{line: strings.TrimSpace(string(line))}
+				return
+			}
+			if err != nil {
+				answers <- answer{err: fmt.Errorf("failed to read answer: %w", err)}
+				return
+			}
+		}
+	}()
+
+	select {
+	case a := <-answers:
+		return a.line, a.err
+	case <-ctx.Done():
+		if abandon() {
+			<-answers
+		}
+		return "", context.Cause(ctx)
+	}
+}
+
+// isTerminal reports whether stdin is an interactive terminal. /dev/null
+// is a character device too, so it is ruled out explicitly.
+func isTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// approval decides whether a plan may be applied. Plans with changes need
+// --auto-approve, --confirm with the environment name, or a yes typed on a
+// terminal. An environment that requires approval does not accept
+// --auto-approve and asks for its name instead of yes.
+type approval struct {
+	required bool
+	opts     plugin.Options
+	// mu keeps prompts of components applied in parallel apart.
+	mu sync.Mutex
+}
+
+func newApproval(config *Config, opts plugin.Options) (*approval, error) {
+	env, _ := config.Environment(opts.Environment)
+	a := &approval{required: env.Spec.RequireApproval, opts: opts}
+
+	if opts.Confirm != "" && opts.Confirm != opts.Environment {
+		return nil, fmt.Errorf("--confirm %s does not match environment %s", opts.Confirm, opts.Environment)
+	}
+	if a.required && opts.AutoApprove && opts.Confirm == "" {
+		return nil, fmt.Errorf("environment %s requires approval and does not accept --auto-approve; approve on a terminal or pass --confirm %s", opts.Environment, opts.Environment)
+	}
+	return a, nil
+}
+
+func (a *approval) approve(ctx context.Context, t target, summary planSummary) error {
+	fmt.Printf("📋 %s in %s: %s\n", t.Component, t.Environment, summary)
+	if summary.Empty() || a.opts.Confirm != "" || (a.opts.AutoApprove && !a.required) {
+		return nil
+	}
+	if !isTerminal() {
+		flag := "--auto-approve"
+		if a.required {
+			flag = "--confirm " + t.Environment
+		}
+		return fmt.Errorf("applying %s to %s needs approval: run on a terminal, or pass %s", t.Component, t.Environment, flag)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prompt, want := fmt.Sprintf("Apply these changes to %s in %s? Type yes to continue: ", t.Component, t.Environment), "yes"
+	if a.required {
+		prompt, want = fmt.Sprintf("Environment %s requires approval. Type its name to apply %s: ", t.Environment, t.Component), t.Environment
+	}
+	answer, err := promptLine(ctx, prompt)
+	if err != nil {
+		return err
+	}
+	if answer != want {
+		return fmt.Errorf("apply of %s to %s was not approved", t.Component, t.Environment)
+	}
+	return nil
+}
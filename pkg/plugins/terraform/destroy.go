@@ -0,0 +1,73 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dknathalage/dkn/pkg/plugin"
+)
+
+// Destroy tears components down in reverse dependency order, so nothing is
+// destroyed while a component that depends on it still exists. It refuses
+// to run in CI unless allowed, and a protected environment must be
+// confirmed by typing its name.
+func (p *TerraformPlugin) Destroy(ctx context.Context, opts plugin.Options) error {
+	config, err := LoadConfig(opts.DeployPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if inCI() && !opts.AllowCI {
+		return fmt.Errorf("refusing to destroy %s from CI, pass --allow-ci to allow it", opts.Environment)
+	}
+
+	targets, err := p.targets(ctx, config, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.Confirm != "" && opts.Confirm != opts.Environment {
+		return fmt.Errorf("--confirm %s does not match environment %s", opts.Confirm, opts.Environment)
+	}
+	if env, ok := config.Environment(opts.Environment); ok && env.Spec.Protected && opts.Confirm == "" {
+		answer, err := promptLine(ctx, fmt.Sprintf("⚠️  Environment %s is protected. Type its name to destroy it: ", opts.Environment))
+		if err != nil {
+			return err
+		}
+		if answer != opts.Environment {
+			return fmt.Errorf("confirmation did not match %s, nothing was destroyed", opts.Environment)
+		}
+	}
+
+	for i := len(targets) - 1; i >= 0; i-- {
+		t := targets[i]
+		fmt.Printf("💥 Destroying component: %s\n", t.Component)
+		err := newTimer(t, opts.Timeout).run(ctx, func(ctx context.Context) error {
+			if err := p.terraformInit(ctx, t); err != nil {
+				return fmt.Errorf("terraform init failed for %s: %w", t.Component, err)
+			}
+
+			if err := p.terraformDestroy(ctx, t, opts.Confirm != ""); err != nil {
+				return fmt.Errorf("terraform destroy failed for %s: %w", t.Component, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Destroyed %s in %s environment\n", t.Component, t.Environment)
+	}
+	return nil
+}
+
+// terraformDestroy leaves confirmation to Terraform itself, so stdin is
+// attached to the child process, unless it was confirmed up front.
+func (p *TerraformPlugin) terraformDestroy(ctx context.Context, t target, confirmed bool) error {
+	args := []string{"destroy", varFileArg(t.Environment)}
+	if confirmed {
+		args = append(args, "-auto-approve")
+	}
+	return p.exec.Run(ctx, Command{Binary: t.Binary, Args: args, Dir: t.Dir, Env: t.Env, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, KillAfter: t.KillAfter})
+}
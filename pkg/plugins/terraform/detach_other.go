@@ -0,0 +1,16 @@
+//go:build !unix
+
+package terraform
+
+import (
+	"os"
+	"os/exec"
+)
+
+func detach(cmd *exec.Cmd) {}
+
+// interrupt kills the process, since os.Interrupt cannot be sent to a
+// process on other platforms.
+func interrupt(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package terraform
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach starts cmd in a process group of its own, so signals sent to the
+// terminal's foreground group do not reach it.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interrupt asks the process to stop, as Ctrl-C would.
+func interrupt(p *os.Process) error {
+	return p.Signal(os.Interrupt)
+}
@@ -0,0 +1,140 @@
+package terraform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/dknathalage/dkn/pkg/plugin"
+)
+
+// Command is a single invocation of Terraform.
+type Command struct {
+	// Binary is the executable, terraform or tofu, looked up in PATH
+	// unless it is a path.
+	Binary string
+	Args   []string
+	// Dir is the working directory, usually a component directory.
+	Dir string
+	// Env is added to the environment dkn runs with.
+	Env    []string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// KillAfter is how long the command may take to stop once interrupted
+	// before it is killed; zero means DefaultKillAfter.
+	KillAfter time.Duration
+}
+
+// DefaultKillAfter bounds how long an interrupted command may take to stop.
+// Terraform uses the time to finish in-flight operations and release its
+// state lock, so it is generous.
+const DefaultKillAfter = time.Minute
+
+// Executor runs Terraform commands. The plugin only talks to Terraform
+// through it, so lifecycle operations can be driven by a fake binary.
+type Executor interface {
+	Run(ctx context.Context, cmd Command) error
+}
+
+// ExitError reports a Terraform command that ran but exited non-zero.
+type ExitError struct {
+	Command Command
+	Code    int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s exited with code %d", e.Command.name(), e.Code)
+}
+
+// name is the binary and subcommand, for messages.
+func (c Command) name() string {
+	if len(c.Args) == 0 {
+		return c.Binary
+	}
+	return c.Binary + " " + c.Args[0]
+}
+
+// flusher is output that holds back an unfinished line, which is written
+// out once the command that produced it has exited.
+type flusher interface {
+	Flush() error
+}
+
+// ProcessExecutor runs Terraform as a child process. When the context is
+// cancelled Terraform is interrupted rather than killed, so it can release
+// its state lock; it is killed once plugin.Killed is closed or when it has
+// not stopped within KillAfter. Platforms that cannot interrupt a process
+// kill it straight away.
+//
+// Terraform aborts without cleaning up on a second interrupt, so it must
+// get exactly one. Commands without stdin run in their own process group,
+// out of reach of the terminal's Ctrl-C, and dkn forwards it. Interactive
+// commands stay in the terminal's group, which already delivers Ctrl-C.
+type ProcessExecutor struct{}
+
+func NewExecutor() *ProcessExecutor {
+	return &ProcessExecutor{}
+}
+
+func (e *ProcessExecutor) Run(ctx context.Context, c Command) error {
+	cmd := exec.CommandContext(ctx, c.Binary, c.Args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	if c.Stdin == nil {
+		detach(cmd)
+	}
+	cmd.Cancel = func() error {
+		if c.Stdin != nil && errors.Is(context.Cause(ctx), plugin.ErrInterrupted) {
+			return nil
+		}
+		return interrupt(cmd.Process)
+	}
+	cmd.WaitDelay = c.KillAfter
+	if cmd.WaitDelay <= 0 {
+		cmd.WaitDelay = DefaultKillAfter
+	}
+
+	if err := cmd.Start(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s not started: %w", c.name(), context.Cause(ctx))
+		}
+		return err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-plugin.Killed(ctx):
+			cmd.Process.Kill()
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	for _, w := range []io.Writer{c.Stdout, c.Stderr} {
+		if f, ok := w.(flusher); ok {
+			if ferr := f.Flush(); err == nil {
+				err = ferr
+			}
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%s stopped: %w", c.name(), context.Cause(ctx))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return &ExitError{Command: c, Code: exitErr.ExitCode()}
+	}
+	return err
+}
@@ -3,46 +3,28 @@ package terraform
 import (
 	"context"
 	"fmt"
-	"os"
+	"path"
 	"path/filepath"
-)
 
-func (p *TerraformPlugin) Gen(ctx context.Context, deployPath string, outputDir string) error {
-	config, err := LoadConfig(deployPath)
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
+	"github.com/dknathalage/dkn/pkg/hcl"
+	"github.com/dknathalage/dkn/pkg/output"
+)
 
-	terraformDir := filepath.Join(outputDir, "terraform")
-	if err := os.MkdirAll(terraformDir, 0755); err != nil {
-		return fmt.Errorf("failed to create terraform directory: %w", err)
-	}
+func (p *TerraformPlugin) Gen(ctx context.Context, config *Config, out *output.Writer) error {
+	terraformDir := "terraform"
 
 	org, repo, err := p.getOrgAndRepo()
 	if err != nil {
 		return fmt.Errorf("failed to get org/repo: %w", err)
 	}
 
-	// Extract environment names
-	var environmentNames []string
-	for _, env := range config.Environments {
-		environmentNames = append(environmentNames, env.Metadata.Name)
-	}
-
 	for _, component := range config.Components {
-		// Use component's environments or environmentRefs if specified, otherwise use all environments
-		componentEnvs := component.Spec.Environments
-		if len(componentEnvs) == 0 {
-			componentEnvs = component.Spec.EnvironmentRefs
-		}
-		if len(componentEnvs) == 0 {
-			componentEnvs = environmentNames
-		}
-
 		genCtx := &GenerateContext{
 			Component:    component.Metadata.Name,
-			Environments: componentEnvs,
-			OutputDir:    terraformDir,
+			Resource:     component,
+			Environments: config.EnvironmentsFor(component),
+			OutputDir:    filepath.Join(terraformDir, component.Metadata.Name),
+			Out:          out,
 			Org:          org,
 			Repo:         repo,
 		}
@@ -51,32 +33,34 @@ func (p *TerraformPlugin) Gen(ctx context.Context, deployPath string, outputDir
 		}
 	}
 
-	fmt.Printf("✅ Generated Terraform configuration in %s\n", terraformDir)
+	if err := p.generateRootTaskfile(out, terraformDir, config); err != nil {
+		return fmt.Errorf("failed to generate Taskfile: %w", err)
+	}
+
+	if !out.DryRun() {
+		fmt.Printf("✅ Generated Terraform configuration in %s\n", filepath.Join(out.Root(), terraformDir))
+	}
 	return nil
 }
 
 func (p *TerraformPlugin) generateComponent(ctx *GenerateContext, config *Config) error {
-	componentDir := filepath.Join(ctx.OutputDir, ctx.Component)
-	if err := os.MkdirAll(componentDir, 0755); err != nil {
+	if err := p.generateMainTf(ctx, config); err != nil {
 		return err
 	}
 
-	tfvarsDir := filepath.Join(componentDir, "tfvars")
-	if err := os.MkdirAll(tfvarsDir, 0755); err != nil {
+	if err := p.generateVariablesTf(ctx, config); err != nil {
 		return err
 	}
 
-	ctx.OutputDir = componentDir
-
-	if err := p.generateVariablesTf(ctx); err != nil {
+	if err := p.generateProviderTf(ctx, config); err != nil {
 		return err
 	}
 
-	if err := p.generateProviderTf(ctx, config); err != nil {
+	if err := p.generateBackendTf(ctx, config); err != nil {
 		return err
 	}
 
-	if err := p.generateBackendTf(ctx, config); err != nil {
+	if err := p.generateTaskfile(ctx, config); err != nil {
 		return err
 	}
 
@@ -85,7 +69,7 @@ func (p *TerraformPlugin) generateComponent(ctx *GenerateContext, config *Config
 	}
 
 	for _, env := range ctx.Environments {
-		if err := p.generateTfvars(ctx, env, tfvarsDir); err != nil {
+		if err := p.generateTfvars(ctx, config, env); err != nil {
 			return err
 		}
 	}
@@ -94,92 +78,326 @@ func (p *TerraformPlugin) generateComponent(ctx *GenerateContext, config *Config
 }
 
 func (p *TerraformPlugin) generateProviderTf(ctx *GenerateContext, config *Config) error {
-	content := "# autogenerated\nterraform {\n  required_providers {\n"
+	file := hcl.NewFile("autogenerated")
+	settings := file.AppendBlock("terraform")
+	if required := config.RequiredVersion(); required != "" {
+		settings.SetAttribute("required_version", hcl.String(required))
+	}
+	requiredProviders := settings.AppendBlock("required_providers")
+	for _, provider := range config.ProvidersFor(ctx.Resource) {
+		requiredProviders.SetAttribute(provider.Name, hcl.Object(map[string]hcl.Value{
+			"source":  hcl.String(provider.Source),
+			"version": hcl.String(provider.Version),
+		}))
+	}
 
-	// Use component-specific providers if available, otherwise use global defaults
-	providers := config.Providers
-	for _, component := range config.Components {
-		if component.Metadata.Name == ctx.Component && len(component.Spec.Providers) > 0 {
-			providers = component.Spec.Providers
-			break
+	// Attributes that vary per environment are wired through variables set
+	// in each environment's tfvars.
+	attributes := config.ProviderAttributes(ctx.Environments)
+	for _, provider := range sortedKeys(attributes) {
+		block := file.AppendBlock("provider", provider)
+		for _, attribute := range attributes[provider] {
+			block.SetAttribute(attribute, hcl.Expr("var."+ProviderVariable(provider, attribute)))
 		}
 	}
 
-	for _, provider := range providers {
-		content += fmt.Sprintf("    %s = {\n", provider.Name)
-		content += fmt.Sprintf("      source = \"%s\"\n", provider.Source)
-		content += fmt.Sprintf("      version = \"%s\"\n", provider.Version)
-		content += "    }\n\n"
+	return ctx.Out.WriteFile(filepath.Join(ctx.OutputDir, "provider.tf"), file.Bytes())
+}
+
+// generateBackendTf writes the local backend when no backend is
+// configured. The setting that locates the state is left to init, since it
+// differs per environment. Workspace-based backends instead select the
+// component's workspaces here and the environment's one through
+// TF_WORKSPACE.
+func (p *TerraformPlugin) generateBackendTf(ctx *GenerateContext, config *Config) error {
+	backend := config.StateBackendFor(ctx.Resource)
+	kind := stateBackends[backend.Type]
+
+	file := hcl.NewFile("autogenerated")
+	settings := file.AppendBlock("terraform")
+	var block *hcl.Block
+	if backend.Type == "cloud" {
+		block = settings.AppendBlock("cloud")
+	} else {
+		block = settings.AppendBlock("backend", backend.Type)
+	}
+	for _, key := range sortedKeys(backend.Config) {
+		if !kind.locates(key) {
+			block.SetAttribute(key, hcl.String(backend.Config[key]))
+		}
 	}
 
-	content += "  }\n}\n"
+	prefix := config.WorkspacePrefix(ctx.Resource, ctx.Org, ctx.Repo)
+	switch backend.Type {
+	case "remote":
+		block.AppendBlock("workspaces").SetAttribute("prefix", hcl.String(prefix+"-"))
+	case "cloud":
+		block.AppendBlock("workspaces").SetAttribute("tags", hcl.List(hcl.String(prefix)))
+	}
 
-	return os.WriteFile(filepath.Join(ctx.OutputDir, "provider.tf"), []byte(content), 0644)
+	return ctx.Out.WriteFile(filepath.Join(ctx.OutputDir, "backend.tf"), file.Bytes())
 }
 
-func (p *TerraformPlugin) generateBackendTf(ctx *GenerateContext, config *Config) error {
-	content := "# autogenerated\nterraform {\n"
-	
-	// Use component-specific backend if available, otherwise use global default
-	backend := config.Backend
-	for _, component := range config.Components {
-		if component.Metadata.Name == ctx.Component && component.Spec.Backend.Type != "" {
-			backend = component.Spec.Backend
-			break
+func (p *TerraformPlugin) generateMainTf(ctx *GenerateContext, config *Config) error {
+	file := hcl.NewFile("autogenerated")
+
+	for _, state := range ctx.Resource.Spec.RemoteStates {
+		remoteStateBlock(ctx, config, file.AppendBlock("data", "terraform_remote_state", state.Component), state)
+	}
+
+	for _, module := range ctx.Resource.Spec.Modules {
+		block := file.AppendBlock("module", module.Name)
+		block.SetAttribute("source", hcl.String(module.Source))
+		if module.Version != "" {
+			block.SetAttribute("version", hcl.String(module.Version))
+		}
+		for _, name := range sortedKeys(module.Inputs) {
+			block.SetAttribute(name, hcl.Interpolated(module.Inputs[name]))
 		}
 	}
-	
-	content += fmt.Sprintf("  backend \"%s\" {\n", backend.Type)
 
-	for key, value := range backend.Config {
-		content += fmt.Sprintf("    %s = \"%s\"\n", key, value)
+	for _, resource := range ctx.Resource.Spec.Resources {
+		writeBlock(file.AppendBlock("resource", resource.Type, resource.Name), resource)
 	}
 
-	content += "  }\n}\n"
+	// Hand-written resources go in the user region, which survives
+	// regeneration.
+	content := append(file.Bytes(), "\n"+output.Region("#", "main")...)
+	return ctx.Out.WriteFile(filepath.Join(ctx.OutputDir, "main.tf"), content)
+}
+
+// defaultWorkspaceDir is where the local backend keeps the state of
+// workspaces other than default.
+const defaultWorkspaceDir = "terraform.tfstate.d"
+
+// remoteStateBlock points a terraform_remote_state data source at another
+// component's state in the current environment, addressed the same way
+// init addresses it. The config is written once with the environment
+// interpolated, or per environment when environments override the backend.
+// Components using the workspace strategy share one config and select the
+// environment's workspace instead.
+func remoteStateBlock(ctx *GenerateContext, config *Config, block *hcl.Block, state RemoteState) {
+	component, _ := config.Component(state.Component)
+	backend := config.StateBackendFor(component)
+	kind := stateBackends[backend.Type]
+
+	// The cloud block is read through the remote backend.
+	if kind.workspaces {
+		block.SetAttribute("backend", hcl.String("remote"))
+	} else {
+		block.SetAttribute("backend", hcl.String(backend.Type))
+	}
 
-	return os.WriteFile(filepath.Join(ctx.OutputDir, "backend.tf"), []byte(content), 0644)
+	// stateConfig renders the configuration for one environment. With
+	// environment set to ${var.environment} the locating settings become
+	// templates that hold for every environment.
+	stateConfig := func(environment string, value func(string) hcl.Value) hcl.Value {
+		settings := config.StateConfig(component, ctx.Org, ctx.Repo, environment)
+		if backend.Type == "local" && component.UsesWorkspaces() && settings["workspace_dir"] == "" {
+			settings["workspace_dir"] = defaultWorkspaceDir
+		}
+
+		values := make(map[string]hcl.Value)
+		for key, v := range settings {
+			// Local state paths are relative to the component that owns them.
+			if backend.Type == "local" && (key == "path" || key == "workspace_dir") && !path.IsAbs(v) {
+				v = path.Join("..", state.Component, v)
+			}
+			if kind.locates(key) {
+				values[key] = value(v)
+			} else {
+				values[key] = hcl.String(v)
+			}
+		}
+		if kind.workspaces {
+			values["workspaces"] = hcl.Object(map[string]hcl.Value{
+				"name": value(config.Workspace(component, ctx.Org, ctx.Repo, environment)),
+			})
+		}
+		return hcl.Object(values)
+	}
+
+	// All workspaces share one backend configuration.
+	if component.UsesWorkspaces() {
+		block.SetAttribute("config", stateConfig("", hcl.String))
+		block.SetAttribute("workspace", hcl.Expr("var.environment"))
+		return
+	}
+
+	overridden := false
+	for _, env := range ctx.Environments {
+		if e, _ := config.Environment(env); len(e.Spec.Backend.Config) > 0 {
+			overridden = true
+		}
+	}
+
+	if !overridden {
+		block.SetAttribute("config", stateConfig("${var.environment}", hcl.Template))
+		return
+	}
+
+	perEnvironment := make(map[string]hcl.Value)
+	for _, env := range ctx.Environments {
+		perEnvironment[env] = stateConfig(env, hcl.String)
+	}
+	block.SetAttribute("config", hcl.Index(hcl.Object(perEnvironment), hcl.Expr("var.environment")))
 }
 
-func (p *TerraformPlugin) generateVariablesTf(ctx *GenerateContext) error {
-	content := `# autogenerated
-variable "project_name" {
-  description = "Name of the project"
-  type        = string
+func writeBlock(block *hcl.Block, spec Block) {
+	for _, name := range sortedKeys(spec.Attributes) {
+		block.SetAttribute(name, hcl.Interpolated(spec.Attributes[name]))
+	}
+	for _, nested := range spec.Blocks {
+		writeBlock(block.AppendBlock(nested.Type), nested)
+	}
 }
 
-variable "component_name" {
-  description = "Name of the component"
-  type        = string
-  default     = "` + ctx.Component + `"
+func (p *TerraformPlugin) generateVariablesTf(ctx *GenerateContext, config *Config) error {
+	file := hcl.NewFile("autogenerated")
+	for _, variable := range componentVariables(ctx, config) {
+		variableBlock(file.AppendBlock("variable", variable.Name), variable)
+	}
+	return ctx.Out.WriteFile(filepath.Join(ctx.OutputDir, "variables.tf"), file.Bytes())
 }
 
-variable "environment" {
-  description = "Environment name"
-  type        = string
+// componentVariables lists every variable a component declares: the
+// built-in ones, its own, then those contributed by its environments.
+// Environment variables already declared by the component are skipped.
+func componentVariables(ctx *GenerateContext, config *Config) []Variable {
+	variables := []Variable{
+		{Name: "project_name", Type: "string", Description: "Name of the project"},
+		{Name: "component_name", Type: "string", Description: "Name of the component", Default: ctx.Component},
+		{Name: "environment", Type: "string", Description: "Environment name"},
+	}
+	variables = append(variables, ctx.Resource.Spec.Variables...)
+
+	declared := make(map[string]bool)
+	for _, variable := range variables {
+		declared[variable.Name] = true
+	}
+
+	envVariables := config.EnvironmentVariables(ctx.Environments)
+	for _, name := range sortedKeys(envVariables) {
+		if declared[name] {
+			continue
+		}
+		description := envVariables[name].Description
+		if description == "" {
+			description = "Set per environment"
+		}
+		variables = append(variables, Variable{Name: name, Type: envVariables[name].Type, Description: description, optional: true})
+	}
+
+	attributes := config.ProviderAttributes(ctx.Environments)
+	for _, provider := range sortedKeys(attributes) {
+		for _, attribute := range attributes[provider] {
+			variables = append(variables, Variable{
+				Name:        ProviderVariable(provider, attribute),
+				Type:        "string",
+				Description: fmt.Sprintf("%s for the %s provider", attribute, provider),
+				optional:    true,
+			})
+		}
+	}
+	return variables
 }
-`
-	return os.WriteFile(filepath.Join(ctx.OutputDir, "variables.tf"), []byte(content), 0644)
+
+func variableBlock(block *hcl.Block, variable Variable) {
+	if variable.Description != "" {
+		block.SetAttribute("description", hcl.String(variable.Description))
+	}
+	block.SetAttribute("type", hcl.Expr(hclType(variable.Type)))
+	if variable.Default != nil {
+		block.SetAttribute("default", hcl.Literal(variable.Default))
+	} else if variable.optional {
+		block.SetAttribute("default", hcl.Null())
+	}
+	if variable.Sensitive {
+		block.SetAttribute("sensitive", hcl.Bool(true))
+	}
+	for _, validation := range variable.Validation {
+		check := block.AppendBlock("validation")
+		check.SetAttribute("condition", hcl.Expr(validation.Condition))
+		check.SetAttribute("error_message", hcl.String(validation.ErrorMessage))
+	}
 }
 
+// generateGitignore keeps Terraform's working files and any local state out
+// of version control, whichever backend or state strategy produced it.
 func (p *TerraformPlugin) generateGitignore(ctx *GenerateContext) error {
-	content := `.terraform
-.terraform*
-`
-	return os.WriteFile(filepath.Join(ctx.OutputDir, ".gitignore"), []byte(content), 0644)
+	content := ".terraform\n.terraform*\n*.tfstate\n*.tfstate.*\nterraform.tfstate.d/\n" + output.Region("#", "gitignore")
+	return ctx.Out.WriteFile(filepath.Join(ctx.OutputDir, ".gitignore"), []byte(content))
 }
 
-func (p *TerraformPlugin) generateTfvars(ctx *GenerateContext, environment string, outputDir string) error {
-	filePath := filepath.Join(outputDir, environment+".tfvars")
+// generateTfvars rewrites the keys dkn owns and keeps any a human added.
+// Keys dkn wrote before but no longer generates are dropped.
+func (p *TerraformPlugin) generateTfvars(ctx *GenerateContext, config *Config, environment string) error {
+	path := filepath.Join(ctx.OutputDir, "tfvars", environment+".tfvars")
 
-	if _, err := os.Stat(filePath); err == nil {
-		return nil
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("error checking if file exists: %w", err)
+	existing, err := ctx.Out.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	current, err := hcl.ParseAttributes(existing)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	file := hcl.NewFile("autogenerated")
+	var keys []string
+	owned := make(map[string]bool)
+	for _, assignment := range tfvarsAssignments(ctx, config, environment) {
+		file.SetAttribute(assignment.Name, hcl.Literal(assignment.Value))
+		keys = append(keys, assignment.Name)
+		owned[assignment.Name] = true
 	}
+	for _, key := range ctx.Out.OwnedKeys(path) {
+		owned[key] = true
+	}
+	for _, attribute := range current {
+		if !owned[attribute.Name] {
+			file.SetAttribute(attribute.Name, hcl.Expr(attribute.Expr))
+		}
+	}
+	return ctx.Out.WriteMerged(path, file.Bytes(), keys)
+}
+
+type assignment struct {
+	Name  string
+	Value interface{}
+}
 
-	content := `# autogenerated
-component_name = "` + ctx.Component + `"
-environment = "` + environment + `"
-`
-	return os.WriteFile(filePath, []byte(content), 0644)
-}
\ No newline at end of file
+// tfvarsAssignments returns the values written to an environment's tfvars.
+// A component's per-environment value overrides the environment's own.
+func tfvarsAssignments(ctx *GenerateContext, config *Config, environment string) []assignment {
+	assignments := []assignment{
+		{"project_name", config.ProjectName()},
+		{"component_name", ctx.Component},
+		{"environment", environment},
+	}
+	if config.ProjectName() == "" {
+		assignments[0].Value = ctx.Repo
+	}
+
+	env, _ := config.Environment(environment)
+	values := make(map[string]interface{})
+	for name, variable := range env.Spec.Variables {
+		values[name] = variable.Value
+	}
+	for _, variable := range ctx.Resource.Spec.Variables {
+		if value, ok := variable.Values[environment]; ok {
+			values[variable.Name] = value
+		}
+	}
+	for _, name := range sortedKeys(values) {
+		assignments = append(assignments, assignment{name, values[name]})
+	}
+
+	for _, provider := range sortedKeys(env.Spec.Providers) {
+		attributes := env.Spec.Providers[provider]
+		for _, attribute := range sortedKeys(attributes) {
+			assignments = append(assignments, assignment{ProviderVariable(provider, attribute), attributes[attribute]})
+		}
+	}
+	return assignments
+}
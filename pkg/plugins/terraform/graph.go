@@ -0,0 +1,174 @@
+package terraform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Ordered returns the components in dependency order: every component
+// comes after the ones it depends on, otherwise they keep their config
+// order. Dependencies on unknown components are ignored here and reported
+// by validation.
+func (c *Config) Ordered() ([]TerraformResource, error) {
+	if cycle := c.dependencyCycle(); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	placed := make(map[string]bool)
+	var ordered []TerraformResource
+	for len(ordered) < len(c.Components) {
+		progress := false
+		for _, component := range c.Components {
+			if placed[component.Metadata.Name] || !c.dependenciesPlaced(component, placed) {
+				continue
+			}
+			placed[component.Metadata.Name] = true
+			ordered = append(ordered, component)
+			progress = true
+		}
+		if !progress {
+			return nil, fmt.Errorf("cannot order components: %d of %d could not be placed", len(c.Components)-len(ordered), len(c.Components))
+		}
+	}
+	return ordered, nil
+}
+
+func (c *Config) dependenciesPlaced(component TerraformResource, placed map[string]bool) bool {
+	for _, dependency := range component.Dependencies() {
+		if _, known := c.Component(dependency); known && !placed[dependency] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Config) Component(name string) (TerraformResource, bool) {
+	for _, component := range c.Components {
+		if component.Metadata.Name == name {
+			return component, true
+		}
+	}
+	return TerraformResource{}, false
+}
+
+// dependencyCycle returns the first cycle found, starting and ending with
+// the same component, or nil.
+func (c *Config) dependencyCycle() []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, step := range path {
+				if step == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		case visited:
+			return nil
+		}
+
+		component, ok := c.Component(name)
+		if !ok {
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range component.Dependencies() {
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, component := range c.Components {
+		if cycle := visit(component.Metadata.Name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// runOrdered calls run for every target once the targets it depends on
+// have succeeded, with at most parallelism running at once. Targets must
+// be in dependency order. After a failure or once ctx is cancelled no new
+// target starts, and the errors are returned once the running ones finish.
+func runOrdered(ctx context.Context, targets []target, parallelism int, run func(target) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	selected := make(map[string]bool)
+	for _, t := range targets {
+		selected[t.Component] = true
+	}
+
+	type result struct {
+		component string
+		err       error
+	}
+	results := make(chan result)
+	started := make([]bool, len(targets))
+	succeeded := make(map[string]bool)
+	running := 0
+	var errs []error
+
+	ready := func(t target) bool {
+		for _, dependency := range t.DependsOn {
+			if selected[dependency] && !succeeded[dependency] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for {
+		for i, t := range targets {
+			if len(errs) > 0 || ctx.Err() != nil || running == parallelism {
+				break
+			}
+			if started[i] || !ready(t) {
+				continue
+			}
+			started[i] = true
+			running++
+			go func(t target) {
+				results <- result{t.Component, run(t)}
+			}(t)
+		}
+
+		if running == 0 {
+			if len(errs) == 0 && ctx.Err() != nil && !allStarted(started) {
+				return context.Cause(ctx)
+			}
+			return errors.Join(errs...)
+		}
+		r := <-results
+		running--
+		if r.err != nil {
+			errs = append(errs, r.err)
+		} else {
+			succeeded[r.component] = true
+		}
+	}
+}
+
+func allStarted(started []bool) bool {
+	for _, s := range started {
+		if !s {
+			return false
+		}
+	}
+	return true
+}
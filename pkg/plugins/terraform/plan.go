@@ -0,0 +1,132 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dknathalage/dkn/pkg/plugin"
+)
+
+// Plan saves a plan per component so apply can later apply exactly what
+// was reviewed, and prints what each plan would change.
+func (p *TerraformPlugin) Plan(ctx context.Context, opts plugin.Options) error {
+	config, err := LoadConfig(opts.DeployPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	targets, err := p.targets(ctx, config, opts)
+	if err != nil {
+		return err
+	}
+
+	summaries := make([]planSummary, len(targets))
+	for i, t := range targets {
+		err := newTimer(t, opts.Timeout).run(ctx, func(ctx context.Context) error {
+			if err := p.terraformInit(ctx, t); err != nil {
+				return fmt.Errorf("terraform init failed for %s: %w", t.Component, err)
+			}
+
+			if err := p.terraformPlan(ctx, t); err != nil {
+				return fmt.Errorf("terraform plan failed for %s: %w", t.Component, err)
+			}
+
+			var err error
+			if summaries[i], err = p.showPlan(ctx, t); err != nil {
+				return fmt.Errorf("failed to read plan for %s: %w", t.Component, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n📋 Plan summary for %s environment:\n", opts.Environment)
+	for i, t := range targets {
+		fmt.Printf("  %s: %s\n", t.Component, summaries[i])
+	}
+	return nil
+}
+
+func (p *TerraformPlugin) terraformPlan(ctx context.Context, t target) error {
+	if err := os.MkdirAll(filepath.Dir(t.planPath()), 0755); err != nil {
+		return err
+	}
+
+	fmt.Printf("📋 Planning Terraform changes for %s in %s environment...\n", t.Component, t.Environment)
+	return p.run(ctx, t, "plan", varFileArg(t.Environment), "-out="+t.planFile())
+}
+
+// planFile is where the saved plan of a target lives, relative to its
+// directory. It sits in .terraform so it is never committed and goes away
+// with dkn clean.
+func (t target) planFile() string {
+	return filepath.Join(".terraform", t.Environment+".tfplan")
+}
+
+func (t target) planPath() string {
+	return filepath.Join(t.Dir, t.planFile())
+}
+
+// showPlan summarises the saved plan of a target.
+func (p *TerraformPlugin) showPlan(ctx context.Context, t target) (planSummary, error) {
+	var output bytes.Buffer
+	cmd := Command{Binary: t.Binary, Args: []string{"show", "-json", t.planFile()}, Dir: t.Dir, Env: t.Env, Stdout: &output, Stderr: t.stderr(), KillAfter: t.KillAfter}
+	if err := p.exec.Run(ctx, cmd); err != nil {
+		return planSummary{}, err
+	}
+	return summarizePlan(output.Bytes())
+}
+
+// planSummary counts resource changes the way terraform plan reports them:
+// a replacement is both an add and a destroy.
+type planSummary struct {
+	Add     int
+	Change  int
+	Destroy int
+}
+
+func (s planSummary) String() string {
+	if s.Empty() {
+		return "no changes"
+	}
+	return fmt.Sprintf("%d to add, %d to change, %d to destroy", s.Add, s.Change, s.Destroy)
+}
+
+func (s planSummary) Empty() bool {
+	return s.Add == 0 && s.Change == 0 && s.Destroy == 0
+}
+
+// summarizePlan reads the resource changes of terraform show -json output.
+func summarizePlan(data []byte) (planSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Change struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return planSummary{}, fmt.Errorf("unexpected terraform show output: %w", err)
+	}
+
+	var summary planSummary
+	for _, resource := range plan.ResourceChanges {
+		for _, action := range resource.Change.Actions {
+			switch action {
+			case "create":
+				summary.Add++
+			case "update":
+				summary.Change++
+			case "delete":
+				summary.Destroy++
+			}
+		}
+	}
+	return summary, nil
+}
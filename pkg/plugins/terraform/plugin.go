@@ -7,32 +7,49 @@ import (
 	"os/exec"
 	"regexp"
 	"strings"
+
+	"github.com/dknathalage/dkn/pkg/config"
+	"github.com/dknathalage/dkn/pkg/output"
 )
 
-type TerraformPlugin struct{}
+type TerraformPlugin struct {
+	exec Executor
+}
 
 type GenerateContext struct {
 	Component    string
+	Resource     TerraformResource
 	Environments []string
-	OutputDir    string
-	Org          string
-	Repo         string
+	// OutputDir is the component directory relative to the output root.
+	OutputDir string
+	Out       *output.Writer
+	Org       string
+	Repo      string
 }
 
 func New() *TerraformPlugin {
-	return &TerraformPlugin{}
+	return NewWithExecutor(NewExecutor())
+}
+
+// NewWithExecutor returns a plugin that runs Terraform through executor.
+func NewWithExecutor(executor Executor) *TerraformPlugin {
+	return &TerraformPlugin{exec: executor}
 }
 
 func (p *TerraformPlugin) Name() string {
 	return "terraform"
 }
 
-func (p *TerraformPlugin) ConfigFile() string {
-	return "deploy/*/*.yaml"
+func (p *TerraformPlugin) Kinds() []string {
+	return []string{KindProject, KindEnvironment, KindTerraform}
 }
 
-func (p *TerraformPlugin) Generate(ctx context.Context, configPath string, outputDir string) error {
-	return p.Gen(ctx, "deploy", outputDir)
+func (p *TerraformPlugin) Generate(ctx context.Context, resources []*config.Resource, out *output.Writer) error {
+	config, err := NewConfig(resources)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return p.Gen(ctx, config, out)
 }
 
 func (p *TerraformPlugin) getOrgAndRepo() (string, string, error) {
@@ -78,4 +95,3 @@ func (p *TerraformPlugin) getOrgAndRepo() (string, string, error) {
 
 	return org, repo, nil
 }
-
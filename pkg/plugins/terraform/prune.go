@@ -0,0 +1,89 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dknathalage/dkn/pkg/config"
+	"github.com/dknathalage/dkn/pkg/output"
+)
+
+// Prune removes the generated files of components that are no longer
+// configured, and the tfvars of environments a component is no longer
+// deployed to.
+func (p *TerraformPlugin) Prune(ctx context.Context, resources []*config.Resource, out *output.Writer) error {
+	config, err := NewConfig(resources)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return p.prune(config, out)
+}
+
+func (p *TerraformPlugin) prune(config *Config, out *output.Writer) error {
+	environments := make(map[string]map[string]bool)
+	for _, component := range config.Components {
+		environments[component.Metadata.Name] = make(map[string]bool)
+		for _, env := range config.EnvironmentsFor(component) {
+			environments[component.Metadata.Name][env] = true
+		}
+	}
+
+	warned := make(map[string]bool)
+	for _, name := range out.Files("terraform") {
+		// Paths look like terraform/<component>/..., or
+		// terraform/<component>/tfvars/<environment>.tfvars.
+		parts := strings.Split(name, "/")
+		if len(parts) < 3 {
+			continue
+		}
+		component := parts[1]
+
+		envs, configured := environments[component]
+		var environment string
+		if configured && len(parts) == 4 && parts[2] == "tfvars" {
+			environment = strings.TrimSuffix(parts[3], ".tfvars")
+			if envs[environment] {
+				continue
+			}
+		} else if configured {
+			continue
+		}
+
+		// Warn once per removed component, or per environment it is no
+		// longer deployed to.
+		key := component + "/" + environment
+		if !warned[key] && hasState(filepath.Join(out.Root(), "terraform", component)) {
+			if environment == "" {
+				fmt.Printf("⚠️  Terraform state may still exist for %s; destroy its resources before removing it from config, or clean up its state by hand\n", component)
+			} else {
+				fmt.Printf("⚠️  Terraform state may still exist for %s in %s; destroy its resources there before removing the environment from it, or clean up that state by hand\n", component, environment)
+			}
+			warned[key] = true
+		}
+		removed, err := out.Remove(filepath.FromSlash(name))
+		if err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+		if removed {
+			fmt.Printf("🗑️  Removed %s\n", name)
+		}
+	}
+	return nil
+}
+
+// hasState reports whether a component directory was ever initialised or
+// holds local state, in which case deployed resources may outlive it. Local
+// state is kept under DefaultLocalStateDir by the local backend, which is
+// also used without a backend, in the workspace directory by the workspace
+// strategy, and in terraform.tfstate by Terraform on its own.
+func hasState(dir string) bool {
+	for _, name := range []string{".terraform", "terraform.tfstate", DefaultLocalStateDir, defaultWorkspaceDir} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package terraform
+
+import "os"
+
+// openStdin returns stdin. A pending read cannot be abandoned on other
+// platforms.
+func openStdin() (in *os.File, abandon func() bool, release func()) {
+	return os.Stdin, func() bool { return false }, func() {}
+}
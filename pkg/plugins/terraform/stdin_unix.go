@@ -0,0 +1,40 @@
+//go:build unix
+
+package terraform
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	stdinOnce sync.Once
+	// pollableStdin is stdin registered with the runtime poller, so its
+	// reads honour deadlines. It lives as long as the process, since
+	// closing it would close stdin.
+	pollableStdin *os.File
+)
+
+// openStdin returns stdin in non-blocking mode, so a pending read can be
+// abandoned. release puts stdin back in blocking mode for the Terraform
+// commands that inherit it.
+func openStdin() (in *os.File, abandon func() bool, release func()) {
+	if err := syscall.SetNonblock(syscall.Stdin, true); err != nil {
+		return os.Stdin, func() bool { return false }, func() {}
+	}
+	stdinOnce.Do(func() {
+		pollableStdin = os.NewFile(uintptr(syscall.Stdin), os.Stdin.Name())
+	})
+	release = func() { syscall.SetNonblock(syscall.Stdin, false) }
+
+	// Regular files cannot be polled, but their reads never block either.
+	if err := pollableStdin.SetReadDeadline(time.Time{}); err != nil {
+		return pollableStdin, func() bool { return false }, release
+	}
+	abandon = func() bool {
+		return pollableStdin.SetReadDeadline(time.Now()) == nil
+	}
+	return pollableStdin, abandon, release
+}
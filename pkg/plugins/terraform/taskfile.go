@@ -0,0 +1,156 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dknathalage/dkn/pkg/output"
+	"gopkg.in/yaml.v3"
+)
+
+type taskfile struct {
+	Version  string                 `yaml:"version"`
+	Includes map[string]taskInclude `yaml:"includes,omitempty"`
+	Tasks    map[string]task        `yaml:"tasks,omitempty"`
+}
+
+type taskInclude struct {
+	Taskfile string `yaml:"taskfile"`
+	Dir      string `yaml:"dir"`
+	Flatten  bool   `yaml:"flatten"`
+}
+
+type task struct {
+	Desc          string             `yaml:"desc"`
+	Requires      taskRequires       `yaml:"requires"`
+	Preconditions []taskPrecondition `yaml:"preconditions"`
+	Env           map[string]string  `yaml:"env,omitempty"`
+	Cmds          []interface{}      `yaml:"cmds"`
+}
+
+type taskRequires struct {
+	Vars []string `yaml:"vars"`
+}
+
+type taskPrecondition struct {
+	Sh  string `yaml:"sh"`
+	Msg string `yaml:"msg"`
+}
+
+type taskCall struct {
+	Task string            `yaml:"task"`
+	Vars map[string]string `yaml:"vars"`
+}
+
+// generateTaskfile writes tf:<component>:init|plan|apply|destroy tasks that
+// take the environment as ENV, so stacks can be operated without dkn.
+func (p *TerraformPlugin) generateTaskfile(ctx *GenerateContext, config *Config) error {
+	prefix := "tf:" + ctx.Component + ":"
+	envCheck := taskPrecondition{
+		Sh:  fmt.Sprintf(`case "{{.ENV}}" in %s) ;; *) exit 1 ;; esac`, strings.Join(ctx.Environments, "|")),
+		Msg: fmt.Sprintf("ENV must be one of: %s", strings.Join(ctx.Environments, ", ")),
+	}
+	// Workspace-based backends find the state through the environment.
+	var env map[string]string
+	for _, variable := range config.StateEnv(ctx.Resource, ctx.Org, ctx.Repo, "{{.ENV}}") {
+		name, value, _ := strings.Cut(variable, "=")
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[name] = value
+	}
+	newTask := func(desc string, cmds ...interface{}) task {
+		return task{
+			Desc:          fmt.Sprintf(desc, ctx.Component),
+			Requires:      taskRequires{Vars: []string{"ENV"}},
+			Preconditions: []taskPrecondition{envCheck},
+			Env:           env,
+			Cmds:          cmds,
+		}
+	}
+	initFirst := taskCall{Task: prefix + "init", Vars: map[string]string{"ENV": "{{.ENV}}"}}
+	binary := config.Binary()
+	varFile := "-var-file=tfvars/{{.ENV}}.tfvars"
+
+	tf := taskfile{
+		Version: "3",
+		Tasks: map[string]task{
+			prefix + "init":    newTask("Initialise %s for ENV", initCmds(ctx, config, binary)...),
+			prefix + "plan":    newTask("Plan %s in ENV", initFirst, binary+" plan "+varFile),
+			prefix + "apply":   newTask("Apply %s to ENV", initFirst, binary+" apply "+varFile),
+			prefix + "destroy": newTask("Destroy %s in ENV", initFirst, binary+" destroy "+varFile),
+		},
+	}
+	return writeTaskfile(ctx.Out, filepath.Join(ctx.OutputDir, "Taskfile.yaml"), tf)
+}
+
+// initCmds initialises the component and, with the workspace strategy,
+// selects the environment's workspace.
+func initCmds(ctx *GenerateContext, config *Config, binary string) []interface{} {
+	cmds := []interface{}{initCommand(ctx, config)}
+	if args := config.SelectWorkspaceArgs(ctx.Resource, "{{.ENV}}"); args != nil {
+		cmds = append(cmds, binary+" "+strings.Join(args, " "))
+	}
+	return cmds
+}
+
+// initCommand selects each environment's init arguments with a template
+// conditional, since the state location differs per environment.
+func initCommand(ctx *GenerateContext, config *Config) string {
+	var command string
+	for i, env := range ctx.Environments {
+		keyword := "else if"
+		if i == 0 {
+			keyword = "if"
+		}
+		args := config.InitArgs(ctx.Resource, ctx.Org, ctx.Repo, env)
+		command += fmt.Sprintf("{{%s eq .ENV %q}}%s %s", keyword, env, config.Binary(), shellJoin(args))
+	}
+	return command + "{{end}}"
+}
+
+// generateRootTaskfile includes every component's Taskfile so all tasks can
+// be run from the terraform directory.
+func (p *TerraformPlugin) generateRootTaskfile(out *output.Writer, terraformDir string, config *Config) error {
+	tf := taskfile{
+		Version:  "3",
+		Includes: make(map[string]taskInclude),
+	}
+	for _, component := range config.Components {
+		name := component.Metadata.Name
+		tf.Includes[name] = taskInclude{
+			Taskfile: "./" + name + "/Taskfile.yaml",
+			Dir:      "./" + name,
+			Flatten:  true,
+		}
+	}
+	return writeTaskfile(out, filepath.Join(terraformDir, "Taskfile.yaml"), tf)
+}
+
+func writeTaskfile(out *output.Writer, path string, tf taskfile) error {
+	var buf bytes.Buffer
+	buf.WriteString("# autogenerated\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(tf); err != nil {
+		return err
+	}
+	return out.WriteFile(path, buf.Bytes())
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,{}-]+$`)
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafe.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,97 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+)
+
+var terraformTypes = map[string]bool{
+	"string": true, "number": true, "bool": true,
+	"list": true, "set": true, "map": true, "object": true, "tuple": true,
+}
+
+// checkType reports whether v can be assigned to a variable of the given
+// Terraform type. Structural types are not inspected further.
+func checkType(typ string, v interface{}) error {
+	typ = strings.ReplaceAll(typ, " ", "")
+	if typ == "" || typ == "any" {
+		return nil
+	}
+
+	outer, inner, nested := strings.Cut(typ, "(")
+	if nested {
+		if !strings.HasSuffix(inner, ")") {
+			return fmt.Errorf("invalid type %q", typ)
+		}
+		inner = strings.TrimSuffix(inner, ")")
+	}
+	// Primitive types take no argument, collection and structural types do.
+	primitive := outer == "string" || outer == "number" || outer == "bool"
+	if !terraformTypes[outer] || nested == primitive {
+		return fmt.Errorf("unknown type %q", typ)
+	}
+	if v == nil {
+		return nil
+	}
+
+	switch outer {
+	case "string":
+		switch v.(type) {
+		case string, int, int64, float64, bool:
+			return nil
+		}
+		return fmt.Errorf("expected a string")
+	case "number":
+		switch v.(type) {
+		case int, int64, float64:
+			return nil
+		}
+		return fmt.Errorf("expected a number")
+	case "bool":
+		if _, ok := v.(bool); ok {
+			return nil
+		}
+		return fmt.Errorf("expected a bool")
+	case "list", "set":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected a list")
+		}
+		for i, item := range items {
+			if err := checkType(inner, item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return nil
+	case "map":
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected a map")
+		}
+		for _, key := range sortedKeys(values) {
+			if err := checkType(inner, values[key]); err != nil {
+				return fmt.Errorf("key %s: %w", key, err)
+			}
+		}
+		return nil
+	case "object":
+		if _, ok := v.(map[string]interface{}); !ok {
+			return fmt.Errorf("expected an object")
+		}
+		return nil
+	case "tuple":
+		if _, ok := v.([]interface{}); !ok {
+			return fmt.Errorf("expected a tuple")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown type %q", typ)
+}
+
+// hclType returns the type expression for a variable declaration.
+func hclType(typ string) string {
+	if typ == "" {
+		return "any"
+	}
+	return typ
+}
@@ -0,0 +1,394 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dknathalage/dkn/pkg/config"
+)
+
+// kindTypes maps each kind this plugin handles to the type it decodes into.
+var kindTypes = map[string]interface{}{
+	KindProject:     Project{},
+	KindEnvironment: Environment{},
+	KindTerraform:   TerraformResource{},
+}
+
+func (p *TerraformPlugin) KindTypes() map[string]interface{} {
+	return kindTypes
+}
+
+func (p *TerraformPlugin) Validate(ctx context.Context, resources []*config.Resource) error {
+	var errs config.ErrorList
+	var project *config.Resource
+	components := make(map[string]bool)
+	duplicate := false
+	for _, resource := range resources {
+		if v, ok := kindTypes[resource.Kind]; ok {
+			errs = append(errs, resource.Check(v)...)
+		}
+		if resource.Kind == KindTerraform {
+			duplicate = duplicate || components[resource.Metadata.Name]
+			components[resource.Metadata.Name] = true
+		}
+		if resource.Kind == KindProject {
+			if project != nil {
+				errs = append(errs, resource.Errorf("", "only one Project may be declared, first defined in %s", project.Location()))
+			} else {
+				project = resource
+			}
+		}
+	}
+
+	// Semantic checks need documents that decode cleanly and components
+	// with unique names, which config.Validate already reports.
+	if len(errs) > 0 || duplicate {
+		return errs.Err()
+	}
+
+	config, err := NewConfig(resources)
+	if err != nil {
+		return err
+	}
+
+	if config.Project != nil {
+		errs = append(errs, checkStatePrefix(config)...)
+		errs = append(errs, checkBinary(config.Project)...)
+	}
+
+	known := make(map[string]bool)
+	types := make(map[string]string)
+	for _, env := range config.Environments {
+		known[env.Metadata.Name] = true
+		for _, name := range sortedKeys(env.Spec.Variables) {
+			variable := env.Spec.Variables[name]
+			if err := checkType(variable.Type, variable.Value); err != nil {
+				errs = append(errs, env.resource.Errorf("spec.variables."+name+".value", "variable %s: %v", name, err))
+			}
+			if typ, ok := types[name]; ok && typ != variable.Type {
+				errs = append(errs, env.resource.Errorf("spec.variables."+name+".type", "variable %s is declared as %q in another environment", name, typ))
+			}
+			types[name] = variable.Type
+		}
+	}
+
+	// The project backend is shared, so its problems are reported once.
+	reported := make(map[string]bool)
+	for _, component := range config.Components {
+		errs = append(errs, checkEnvironmentRefs(component, "environments", component.Spec.Environments, known)...)
+		errs = append(errs, checkEnvironmentRefs(component, "environmentRefs", component.Spec.EnvironmentRefs, known)...)
+		errs = append(errs, checkVariables(component, config.EnvironmentsFor(component))...)
+		errs = append(errs, checkModules(component)...)
+		errs = append(errs, checkResources(component)...)
+		errs = append(errs, checkDependencies(config, component)...)
+		errs = append(errs, checkRemoteStates(config, component)...)
+		errs = append(errs, checkStateStrategy(config, component)...)
+		// Without environments there is nothing to generate tfvars, state
+		// locations or Taskfile conditions for.
+		if len(config.EnvironmentsFor(component)) == 0 {
+			if !component.Spec.EnvironmentSelector.Empty() {
+				errs = append(errs, component.resource.Errorf("spec.environmentSelector", "environmentSelector of component %s matches no environments", component.Metadata.Name))
+			} else {
+				errs = append(errs, component.resource.Errorf("metadata.name", "component %s is not deployed to any environment, declare an Environment", component.Metadata.Name))
+			}
+		}
+
+		if config.BackendFor(component).Type != "" {
+			for _, err := range checkBackend(config, component) {
+				if !reported[err.Error()] {
+					reported[err.Error()] = true
+					errs = append(errs, err)
+				}
+			}
+			continue
+		}
+		for _, name := range config.EnvironmentsFor(component) {
+			if env, ok := config.Environment(name); ok && len(env.Spec.Backend.Config) > 0 {
+				errs = append(errs, env.resource.Errorf("spec.backend", "environment %s sets backend config but component %s has no backend type", name, component.Metadata.Name))
+			}
+		}
+	}
+	if cycle := config.dependencyCycle(); cycle != nil {
+		component, _ := config.Component(cycle[0])
+		errs = append(errs, component.resource.Errorf("spec.dependsOn", "dependency cycle: %s", strings.Join(cycle, " -> ")))
+	}
+	return errs.Err()
+}
+
+// checkDependencies reports dependencies on unknown components. Cycles,
+// including a component depending on itself, are reported separately.
+func checkDependencies(c *Config, component TerraformResource) config.ErrorList {
+	var errs config.ErrorList
+	for i, dependency := range component.Spec.DependsOn {
+		if _, ok := c.Component(dependency); !ok {
+			errs = append(errs, component.resource.Errorf(fmt.Sprintf("spec.dependsOn[%d]", i), "component %s depends on unknown component %q", component.Metadata.Name, dependency))
+		}
+	}
+	return errs
+}
+
+// checkRemoteStates requires every component whose state is read to be
+// deployed to each environment the reading component is deployed to.
+func checkRemoteStates(c *Config, component TerraformResource) config.ErrorList {
+	var errs config.ErrorList
+	seen := make(map[string]bool)
+	for i, state := range component.Spec.RemoteStates {
+		path := fmt.Sprintf("spec.remoteStates[%d].component", i)
+		referenced, ok := c.Component(state.Component)
+		switch {
+		case state.Component == component.Metadata.Name:
+			errs = append(errs, component.resource.Errorf(path, "component %s cannot read its own remote state", state.Component))
+			continue
+		case !ok:
+			errs = append(errs, component.resource.Errorf(path, "component %s reads the state of unknown component %q", component.Metadata.Name, state.Component))
+			continue
+		case seen[state.Component]:
+			errs = append(errs, component.resource.Errorf(path, "duplicate remote state %s", state.Component))
+		}
+		seen[state.Component] = true
+
+		deployed := c.EnvironmentsFor(referenced)
+		for _, env := range c.EnvironmentsFor(component) {
+			if !contains(deployed, env) {
+				errs = append(errs, component.resource.Errorf(path, "component %s reads the state of %s, which is not deployed to environment %s", component.Metadata.Name, state.Component, env))
+			}
+		}
+	}
+	return errs
+}
+
+// checkStateStrategy requires a known strategy. The workspace strategy
+// needs a backend that supports workspaces and does not already select
+// them, and one backend configuration shared by every environment.
+func checkStateStrategy(c *Config, component TerraformResource) config.ErrorList {
+	switch component.Spec.StateStrategy {
+	case "", StateStrategyPrefix:
+		return nil
+	case StateStrategyWorkspace:
+	default:
+		return config.ErrorList{component.resource.Errorf("spec.stateStrategy", "unknown state strategy %q, expected %s or %s", component.Spec.StateStrategy, StateStrategyPrefix, StateStrategyWorkspace)}
+	}
+
+	backend := c.BackendFor(component)
+	switch {
+	case backend.Type == "":
+		return nil
+	case backend.Type == "http":
+		return config.ErrorList{component.resource.Errorf("spec.stateStrategy", "the http backend does not support workspaces")}
+	case stateBackends[backend.Type].workspaces:
+		return config.ErrorList{component.resource.Errorf("spec.stateStrategy", "the %s backend already keeps each environment in its own workspace", backend.Type)}
+	}
+
+	var errs config.ErrorList
+	for _, name := range c.EnvironmentsFor(component) {
+		if env, ok := c.Environment(name); ok && len(env.Spec.Backend.Config) > 0 {
+			errs = append(errs, env.resource.Errorf("spec.backend.config", "environment %s sets backend config, but component %s shares one backend across environments with the workspace state strategy", name, component.Metadata.Name))
+		}
+	}
+	return errs
+}
+
+// checkBackend validates the backend of a component in each environment
+// it is deployed to: a supported type, no settings dkn derives from the
+// state prefix and every setting the type requires, which environments
+// may provide.
+func checkBackend(c *Config, component TerraformResource) config.ErrorList {
+	backend := c.BackendFor(component)
+	owner := component.resource
+	if component.Spec.Backend.Type == "" {
+		owner = c.Project.resource
+	}
+
+	kind, ok := stateBackends[backend.Type]
+	if !ok {
+		return config.ErrorList{owner.Errorf("spec.backend.type", "unsupported backend type %q, expected one of %s", backend.Type, strings.Join(sortedKeys(stateBackends), ", "))}
+	}
+
+	var errs config.ErrorList
+	for _, key := range kind.derived() {
+		if _, ok := backend.Config[key]; ok {
+			errs = append(errs, owner.Errorf("spec.backend.config."+key, "%s backend: %s is set by dkn from naming.statePrefix", backend.Type, key))
+		}
+	}
+
+	missing := make(map[string][]string)
+	for _, name := range c.EnvironmentsFor(component) {
+		env, ok := c.Environment(name)
+		if !ok {
+			continue
+		}
+		overrides := env.Spec.Backend.Config
+		if backend.Type == "cloud" && len(overrides) > 0 {
+			errs = append(errs, env.resource.Errorf("spec.backend.config", "environment %s sets backend config, which the cloud block of component %s does not take", name, component.Metadata.Name))
+			continue
+		}
+		for _, key := range kind.derived() {
+			if _, ok := overrides[key]; ok {
+				errs = append(errs, env.resource.Errorf("spec.backend.config."+key, "%s backend: %s is set by dkn from naming.statePrefix", backend.Type, key))
+			}
+		}
+		for _, key := range kind.required {
+			_, configured := backend.Config[key]
+			if _, overridden := overrides[key]; !configured && !overridden {
+				missing[key] = append(missing[key], name)
+			}
+		}
+	}
+	for _, key := range kind.required {
+		if envs := missing[key]; len(envs) > 0 {
+			errs = append(errs, owner.Errorf("spec.backend.config", "%s backend needs %s, set it here or in environment %s", backend.Type, key, strings.Join(envs, ", ")))
+		}
+	}
+	return errs
+}
+
+func checkEnvironmentRefs(component TerraformResource, field string, envs []string, known map[string]bool) config.ErrorList {
+	var errs config.ErrorList
+	for i, env := range envs {
+		if !known[env] {
+			errs = append(errs, component.resource.Errorf(fmt.Sprintf("spec.%s[%d]", field, i), "component %s references unknown environment %q", component.Metadata.Name, env))
+		}
+	}
+	return errs
+}
+
+// checkStatePrefix makes sure the state prefix keeps states apart. Every
+// component needs {component}, and components using the prefix strategy
+// need {environment} as well. The workspace strategy and workspace-based
+// backends keep environments apart without it.
+func checkStatePrefix(c *Config) config.ErrorList {
+	project := c.Project
+	prefix := project.Spec.Naming.StatePrefix
+	if prefix == "" {
+		return nil
+	}
+	placeholders := strings.NewReplacer("{project}", "", "{org}", "", "{repo}", "", "{component}", "", "{environment}", "").Replace(prefix)
+	if strings.ContainsAny(placeholders, "{}") {
+		return config.ErrorList{project.resource.Errorf("spec.naming.statePrefix", "unknown placeholder in %q, expected {project}, {org}, {repo}, {component} or {environment}", prefix)}
+	}
+
+	var errs config.ErrorList
+	if !strings.Contains(prefix, "{component}") {
+		errs = append(errs, project.resource.Errorf("spec.naming.statePrefix", "state prefix %q has no {component}, so components would share their state", prefix))
+	}
+	if !strings.Contains(prefix, "{environment}") {
+		var shared []string
+		for _, component := range c.Components {
+			if !component.UsesWorkspaces() && !stateBackends[c.StateBackendFor(component).Type].workspaces {
+				shared = append(shared, component.Metadata.Name)
+			}
+		}
+		if len(shared) > 0 {
+			errs = append(errs, project.resource.Errorf("spec.naming.statePrefix", "state prefix %q has no {environment}, so the environments of %s would share their state, add it or use the workspace state strategy", prefix, strings.Join(shared, ", ")))
+		}
+	}
+	return errs
+}
+
+func checkBinary(project *Project) config.ErrorList {
+	var errs config.ErrorList
+	if binary := project.Spec.Binary; binary != "" && !contains(binaries, binary) {
+		errs = append(errs, project.resource.Errorf("spec.binary", "unknown binary %q, expected %s", binary, strings.Join(binaries, " or ")))
+	}
+	if required := project.Spec.RequiredVersion; required != "" {
+		if _, err := parseConstraints(required); err != nil {
+			errs = append(errs, project.resource.Errorf("spec.requiredVersion", "%v", err))
+		}
+	}
+	return errs
+}
+
+var builtinVariables = map[string]bool{
+	"project_name":   true,
+	"component_name": true,
+	"environment":    true,
+}
+
+func checkVariables(component TerraformResource, envs []string) config.ErrorList {
+	var errs config.ErrorList
+	declared := make(map[string]bool)
+	for i, variable := range component.Spec.Variables {
+		path := fmt.Sprintf("spec.variables[%d]", i)
+		switch {
+		case variable.Name == "":
+			errs = append(errs, component.resource.Errorf(path, "variable is missing a name"))
+			continue
+		case builtinVariables[variable.Name]:
+			errs = append(errs, component.resource.Errorf(path+".name", "variable %s is provided by dkn and cannot be redeclared", variable.Name))
+		case declared[variable.Name]:
+			errs = append(errs, component.resource.Errorf(path+".name", "duplicate variable %s", variable.Name))
+		}
+		declared[variable.Name] = true
+
+		if err := checkType(variable.Type, variable.Default); err != nil {
+			errs = append(errs, component.resource.Errorf(path+".default", "variable %s: %v", variable.Name, err))
+		}
+		for _, env := range sortedKeys(variable.Values) {
+			if !contains(envs, env) {
+				errs = append(errs, component.resource.Errorf(path+".values."+env, "variable %s sets a value for environment %q which component %s is not deployed to", variable.Name, env, component.Metadata.Name))
+				continue
+			}
+			if err := checkType(variable.Type, variable.Values[env]); err != nil {
+				errs = append(errs, component.resource.Errorf(path+".values."+env, "variable %s: %v", variable.Name, err))
+			}
+		}
+		for j, validation := range variable.Validation {
+			if validation.Condition == "" || validation.ErrorMessage == "" {
+				errs = append(errs, component.resource.Errorf(fmt.Sprintf("%s.validation[%d]", path, j), "variable %s: validation needs a condition and an errorMessage", variable.Name))
+			}
+		}
+	}
+	return errs
+}
+
+func checkModules(component TerraformResource) config.ErrorList {
+	var errs config.ErrorList
+	names := make(map[string]bool)
+	for i, module := range component.Spec.Modules {
+		path := fmt.Sprintf("spec.modules[%d]", i)
+		if module.Name == "" || module.Source == "" {
+			errs = append(errs, component.resource.Errorf(path, "module needs a name and a source"))
+			continue
+		}
+		if names[module.Name] {
+			errs = append(errs, component.resource.Errorf(path+".name", "duplicate module %s", module.Name))
+		}
+		names[module.Name] = true
+		if module.Version != "" && module.IsLocal() {
+			errs = append(errs, component.resource.Errorf(path+".version", "module %s uses a local source and cannot pin a version", module.Name))
+		}
+	}
+	return errs
+}
+
+func checkResources(component TerraformResource) config.ErrorList {
+	var errs config.ErrorList
+	addresses := make(map[string]bool)
+	for i, resource := range component.Spec.Resources {
+		path := fmt.Sprintf("spec.resources[%d]", i)
+		if resource.Type == "" || resource.Name == "" {
+			errs = append(errs, component.resource.Errorf(path, "resource needs a type and a name"))
+			continue
+		}
+		address := resource.Type + "." + resource.Name
+		if addresses[address] {
+			errs = append(errs, component.resource.Errorf(path+".name", "duplicate resource %s", address))
+		}
+		addresses[address] = true
+		errs = append(errs, checkNestedBlocks(component, path, resource.Blocks)...)
+	}
+	return errs
+}
+
+func checkNestedBlocks(component TerraformResource, path string, blocks []Block) config.ErrorList {
+	var errs config.ErrorList
+	for i, block := range blocks {
+		blockPath := fmt.Sprintf("%s.blocks[%d]", path, i)
+		if block.Type == "" {
+			errs = append(errs, component.resource.Errorf(blockPath, "nested block needs a type"))
+		}
+		errs = append(errs, checkNestedBlocks(component, blockPath, block.Blocks)...)
+	}
+	return errs
+}
@@ -0,0 +1,188 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// checkVersion asks the installed binary for its version and fails when it
+// does not satisfy the project's required version.
+func (p *TerraformPlugin) checkVersion(ctx context.Context, c *Config, dir string) error {
+	required := c.RequiredVersion()
+	if required == "" {
+		return nil
+	}
+	cs, err := parseConstraints(required)
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	cmd := Command{Binary: c.Binary(), Args: []string{"version", "-json"}, Dir: dir, Stdout: &output, Stderr: os.Stderr}
+	if err := p.exec.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to detect the %s version: %w", c.Binary(), err)
+	}
+
+	// OpenTofu reports its version under the same key.
+	var info struct {
+		Version string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal(output.Bytes(), &info); err != nil {
+		return fmt.Errorf("unexpected %s version output: %w", c.Binary(), err)
+	}
+	installed, err := parseVersion(info.Version)
+	if err != nil {
+		return fmt.Errorf("unexpected %s version output: %w", c.Binary(), err)
+	}
+
+	if !cs.allows(installed) {
+		return fmt.Errorf("%s %s is installed but the project requires %s", c.Binary(), installed, required)
+	}
+	return nil
+}
+
+// version is a Terraform or OpenTofu release such as 1.6.2 or 1.7.0-beta1.
+type version struct {
+	segments [3]int
+	// parts is how many segments were written, which ~> depends on.
+	parts      int
+	prerelease string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, v.prerelease, _ = strings.Cut(s, "-")
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, fmt.Errorf("invalid version %q", s)
+		}
+		v.segments[i] = n
+	}
+	v.parts = len(parts)
+	return v, nil
+}
+
+func (v version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.segments[0], v.segments[1], v.segments[2])
+	if v.prerelease != "" {
+		s += "-" + v.prerelease
+	}
+	return s
+}
+
+// compare orders versions, placing a prerelease before its release.
+func (v version) compare(other version) int {
+	for i := range v.segments {
+		if v.segments[i] != other.segments[i] {
+			if v.segments[i] < other.segments[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	}
+	return strings.Compare(v.prerelease, other.prerelease)
+}
+
+type constraint struct {
+	op      string
+	version version
+}
+
+// constraints is a required_version expression: comma separated
+// constraints that must all hold.
+type constraints []constraint
+
+var constraintOps = []string{">=", "<=", "!=", "~>", ">", "<", "="}
+
+func parseConstraints(s string) (constraints, error) {
+	var cs constraints
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		op := "="
+		for _, candidate := range constraintOps {
+			if strings.HasPrefix(part, candidate) {
+				op, part = candidate, part[len(candidate):]
+				break
+			}
+		}
+		v, err := parseVersion(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		cs = append(cs, constraint{op: op, version: v})
+	}
+	return cs, nil
+}
+
+// allows reports whether v satisfies every constraint. As in Terraform, a
+// prerelease is only allowed by an exact constraint naming it.
+func (cs constraints) allows(v version) bool {
+	if v.prerelease != "" {
+		exact := false
+		for _, c := range cs {
+			if c.op == "=" && c.version.compare(v) == 0 {
+				exact = true
+			}
+		}
+		if !exact {
+			return false
+		}
+	}
+
+	for _, c := range cs {
+		if !c.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c constraint) allows(v version) bool {
+	cmp := v.compare(c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+
+	// ~> allows only the rightmost written segment to increase.
+	if cmp < 0 {
+		return false
+	}
+	fixed := max(c.version.parts-1, 1)
+	for i := 0; i < fixed; i++ {
+		if v.segments[i] != c.version.segments[i] {
+			return false
+		}
+	}
+	return true
+}
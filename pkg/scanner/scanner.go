@@ -3,7 +3,8 @@ package scanner
 import (
 	"os"
 	"path/filepath"
-	"strings"
+
+	"github.com/dknathalage/dkn/pkg/config"
 )
 
 type FileScanner struct {
@@ -14,70 +15,30 @@ func NewFileScanner(rootDir string) *FileScanner {
 	return &FileScanner{rootDir: rootDir}
 }
 
+// ScanForConfigs returns configuration files below the deploy directory,
+// relative to the root directory. Generated output such as terraform/ is
+// deliberately not scanned.
 func (s *FileScanner) ScanForConfigs() ([]string, error) {
 	var configFiles []string
 
-	// Scan deploy directory recursively
 	deployPath := filepath.Join(s.rootDir, "deploy")
-	if _, err := os.Stat(deployPath); err == nil {
-		err := filepath.Walk(deployPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			
-			if !info.IsDir() && (strings.HasSuffix(info.Name(), ".yaml") || strings.HasSuffix(info.Name(), ".yml")) {
-				relativePath, _ := filepath.Rel(s.rootDir, path)
-				configFiles = append(configFiles, relativePath)
-			}
-			return nil
-		})
-		if err != nil {
-			// Continue even if walk fails
-		}
+	if _, err := os.Stat(deployPath); os.IsNotExist(err) {
+		return configFiles, nil
 	}
 
-	// Scan specific subdirectories for backward compatibility
-	subdirs := []string{"terraform"}
-	
-	for _, subdir := range subdirs {
-		subdirPath := filepath.Join(s.rootDir, subdir)
-		if _, err := os.Stat(subdirPath); os.IsNotExist(err) {
-			continue
-		}
-
-		entries, err := os.ReadDir(subdirPath)
+	err := filepath.Walk(deployPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			continue
+			return nil
 		}
 
-		for _, entry := range entries {
-			if entry.IsDir() {
-				continue
-			}
-
-			filename := entry.Name()
-			if strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml") {
-				relativePath := filepath.Join(subdir, filename)
-				configFiles = append(configFiles, relativePath)
-			}
+		if !info.IsDir() && config.IsConfigFile(info.Name()) {
+			relativePath, _ := filepath.Rel(s.rootDir, path)
+			configFiles = append(configFiles, relativePath)
 		}
-	}
-
-	// Also scan root directory for backward compatibility
-	entries, err := os.ReadDir(s.rootDir)
+		return nil
+	})
 	if err != nil {
-		return configFiles, nil
-	}
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		filename := entry.Name()
-		if strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml") {
-			configFiles = append(configFiles, filename)
-		}
+		return nil, err
 	}
 
 	return configFiles, nil
@@ -86,3 +47,25 @@ func (s *FileScanner) ScanForConfigs() ([]string, error) {
 func (s *FileScanner) GetConfigPath(filename string) string {
 	return filepath.Join(s.rootDir, filename)
 }
+
+// LoadConfigs loads every document from the scanned files. Resource file
+// names are reported relative to the root directory.
+func (s *FileScanner) LoadConfigs() ([]*config.Resource, error) {
+	configFiles, err := s.ScanForConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*config.Resource
+	for _, configFile := range configFiles {
+		loaded, err := config.LoadFile(s.GetConfigPath(configFile))
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range loaded {
+			resource.File = configFile
+		}
+		resources = append(resources, loaded...)
+	}
+	return resources, nil
+}
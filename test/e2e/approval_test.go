@@ -0,0 +1,128 @@
+package e2e
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_ApplyApproval(t *testing.T) {
+	codegenPath := buildCLI(t)
+
+	tests := []struct {
+		name string
+		args []string
+		env  []string
+		// wantErr is empty when apply should succeed.
+		wantErr string
+		want    string
+	}{
+		{
+			name:    "auto-approve rejected",
+			args:    []string{"apply", "-e", "prod", "--auto-approve"},
+			wantErr: "environment prod requires approval and does not accept --auto-approve",
+		},
+		{
+			name:    "confirm must match",
+			args:    []string{"apply", "-e", "prod", "--confirm", "dev"},
+			wantErr: "--confirm dev does not match environment prod",
+		},
+		{
+			name:    "changes need approval without a terminal",
+			args:    []string{"apply", "-e", "dev"},
+			wantErr: "applying api to dev needs approval: run on a terminal, or pass --auto-approve",
+		},
+		{
+			name: "a plan without changes needs no approval",
+			args: []string{"apply", "-e", "dev"},
+			env:  []string{"FAKE_TERRAFORM_NO_CHANGES=1"},
+			want: "api in dev: no changes",
+		},
+		{
+			name: "confirm approves an environment that requires approval",
+			args: []string{"apply", "-e", "prod", "--confirm", "prod"},
+			want: "Applied Terraform changes for api in prod environment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			logPath := filepath.Join(tempDir, "terraform.log")
+			writeApprovalProject(t, tempDir)
+			env := append(fakeTerraformEnv(t, logPath), tt.env...)
+
+			if out, err := runCLI(t, codegenPath, tempDir, env, "gen"); err != nil {
+				t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+			}
+
+			out, err := runCLI(t, codegenPath, tempDir, env, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(out, tt.wantErr) {
+					t.Fatalf("Expected %q, got: %v\nOutput: %s", tt.wantErr, err, out)
+				}
+				// Refusals before planning never run Terraform at all.
+				if calls, _ := os.ReadFile(logPath); strings.Contains(string(calls), "api apply") {
+					t.Errorf("Expected nothing to be applied, got calls:\n%s", calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply failed: %v\nOutput: %s", err, out)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("Expected %q, got: %s", tt.want, out)
+			}
+		})
+	}
+}
+
+func TestCLI_ApplyApprovalPrompt(t *testing.T) {
+	codegenPath := buildCLI(t)
+
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "terraform.log")
+	writeApprovalProject(t, tempDir)
+	env := fakeTerraformEnv(t, logPath)
+
+	if out, err := runCLI(t, codegenPath, tempDir, env, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+
+	ptmx, tty := openPTY(t)
+	if _, err := ptmx.Write([]byte("no\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cmd := cliCommand(codegenPath, tempDir, env, "apply", "-e", "dev")
+	cmd.Stdin = tty
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	want := "apply of api to dev was not approved"
+	if err := cmd.Run(); err == nil || !strings.Contains(out.String(), want) {
+		t.Fatalf("Expected %q, got: %v\nOutput: %s", want, err, out.String())
+	}
+	if calls := readFile(t, logPath); strings.Contains(calls, "api apply") {
+		t.Errorf("Expected nothing to be applied, got calls:\n%s", calls)
+	}
+}
+
+// writeApprovalProject writes a dev environment and a prod environment
+// that requires approval, with one component deployed to both.
+func writeApprovalProject(t *testing.T, root string) {
+	t.Helper()
+
+	writeFiles(t, root, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/environments/prod.yaml": `kind: Environment
+metadata:
+  name: prod
+spec:
+  requireApproval: true
+`,
+		"deploy/terraform/api.yaml": terraformDoc("api"),
+	})
+}
@@ -12,21 +12,12 @@ import (
 func TestCLI_AllPlugins(t *testing.T) {
 	tempDir := t.TempDir()
 	
-	configContent := `components:
-  - api
-  - worker
-environments:
-  - dev
-  - prod`
-
-	terraformDir := filepath.Join(tempDir, "terraform")
-	if err := os.MkdirAll(terraformDir, 0755); err != nil {
-		t.Fatalf("Failed to create terraform directory: %v", err)
-	}
-	configPath := filepath.Join(terraformDir, "terraform.yaml")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		t.Fatalf("Failed to write test config: %v", err)
-	}
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml":  environmentDoc("dev"),
+		"deploy/environments/prod.yaml": environmentDoc("prod"),
+		"deploy/terraform/api.yaml":     terraformDoc("api"),
+		"deploy/terraform/worker.yaml":  terraformDoc("worker"),
+	})
 
 	codegenPath := buildCLI(t)
 	
@@ -51,6 +42,10 @@ environments:
 		t.Error("Expected completion message")
 	}
 
+	if n := strings.Count(string(output), "🔧 Generating with terraform plugin"); n != 1 {
+		t.Errorf("Expected terraform plugin to run once per pass, ran %d times", n)
+	}
+
 	terraformOutputDir := filepath.Join(tempDir, "terraform")
 	if _, err := os.Stat(terraformOutputDir); os.IsNotExist(err) {
 		t.Error("Terraform directory should be created")
@@ -60,19 +55,10 @@ environments:
 func TestCLI_SpecificPlugin(t *testing.T) {
 	tempDir := t.TempDir()
 	
-	configContent := `components:
-  - service
-environments:
-  - test`
-
-	terraformDir := filepath.Join(tempDir, "terraform")
-	if err := os.MkdirAll(terraformDir, 0755); err != nil {
-		t.Fatalf("Failed to create terraform directory: %v", err)
-	}
-	configPath := filepath.Join(terraformDir, "terraform.yaml")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		t.Fatalf("Failed to write test config: %v", err)
-	}
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/test.yaml": environmentDoc("test"),
+		"deploy/terraform/service.yaml": terraformDoc("service"),
+	})
 
 	codegenPath := buildCLI(t)
 	
@@ -131,6 +117,30 @@ func TestCLI_InvalidPlugin(t *testing.T) {
 	}
 }
 
+func TestCLI_UnknownKind(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/helm/chart.yaml":       "kind: HelmRelease\nmetadata:\n  name: chart\n",
+	})
+
+	codegenPath := buildCLI(t)
+
+	cmd := exec.Command(codegenPath)
+	cmd.Dir = tempDir
+	cmd.Env = append(os.Environ(), "GO_TEST_MODE=1")
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, output)
+	}
+
+	if !strings.Contains(string(output), "No plugin handles kind 'HelmRelease'") {
+		t.Errorf("Expected unknown kind warning, got: %s", output)
+	}
+}
+
 func buildCLI(t *testing.T) string {
 	ctx := context.Background()
 	
@@ -155,4 +165,59 @@ func buildCLI(t *testing.T) string {
 	})
 	
 	return codegenPath
-}
\ No newline at end of file
+}
+
+// runCLI runs the CLI built by buildCLI in dir and returns its combined
+// output.
+func runCLI(t *testing.T, codegenPath, dir string, env []string, args ...string) (string, error) {
+	t.Helper()
+
+	out, err := cliCommand(codegenPath, dir, env, args...).CombinedOutput()
+	return string(out), err
+}
+
+// cliCommand prepares a run of the CLI for tests that need to feed it
+// input or signal it. env is added to the test's environment, which runs
+// in test mode and outside CI.
+func cliCommand(codegenPath, dir string, env []string, args ...string) *exec.Cmd {
+	cmd := exec.Command(codegenPath, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), append([]string{"GO_TEST_MODE=1", "CI="}, env...)...)
+	return cmd
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+const projectDoc = `kind: Project
+metadata:
+  name: test-project
+spec:
+  backend:
+    type: gcs
+    config:
+      bucket: test-state
+  providers:
+    - name: google
+      source: hashicorp/google
+      version: 6.46.0
+`
+
+func environmentDoc(name string) string {
+	return "kind: Environment\nmetadata:\n  name: " + name + "\n"
+}
+
+func terraformDoc(name string) string {
+	return "kind: Terraform\nmetadata:\n  name: " + name + "\n"
+}
@@ -0,0 +1,81 @@
+package e2e
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dknathalage/dkn/pkg/config"
+)
+
+func TestConfig_MultiDocumentYAML(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/stack.yaml": environmentDoc("dev") + "---\n" + environmentDoc("prod") + "---\n" + terraformDoc("api"),
+	})
+
+	resources, err := config.LoadDir(filepath.Join(tempDir, "deploy"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if len(resources) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(resources))
+	}
+
+	expected := []struct {
+		kind string
+		name string
+	}{
+		{"Environment", "dev"},
+		{"Environment", "prod"},
+		{"Terraform", "api"},
+	}
+	for i, want := range expected {
+		got := resources[i]
+		if got.Kind != want.kind || got.Metadata.Name != want.name {
+			t.Errorf("Document %d: expected %s/%s, got %s/%s", i, want.kind, want.name, got.Kind, got.Metadata.Name)
+		}
+		if got.Index != i {
+			t.Errorf("Document %d: expected index %d, got %d", i, i, got.Index)
+		}
+		if filepath.Base(got.File) != "stack.yaml" {
+			t.Errorf("Document %d: expected source stack.yaml, got %s", i, got.File)
+		}
+	}
+}
+
+func TestConfig_YmlAndJSON(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yml": environmentDoc("dev"),
+		"deploy/terraform/api.json": `{"kind": "Terraform", "metadata": {"name": "api"}}
+{"kind": "Terraform", "metadata": {"name": "worker"}}`,
+		"deploy/notes.txt": "not a config file",
+	})
+
+	resources, err := config.LoadDir(filepath.Join(tempDir, "deploy"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	names := make(map[string]*config.Resource)
+	for _, resource := range resources {
+		names[resource.Metadata.Name] = resource
+	}
+
+	for _, name := range []string{"dev", "api", "worker"} {
+		if _, ok := names[name]; !ok {
+			t.Errorf("Expected document %s to be loaded", name)
+		}
+	}
+
+	if worker := names["worker"]; worker != nil && worker.Index != 1 {
+		t.Errorf("Expected worker to be the second JSON document, got index %d", worker.Index)
+	}
+
+	if len(resources) != 3 {
+		t.Errorf("Expected 3 documents, got %d", len(resources))
+	}
+}
@@ -0,0 +1,52 @@
+package e2e
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCLI_DestroyGuards(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/environments/prod.yaml": `kind: Environment
+metadata:
+  name: prod
+spec:
+  protected: true
+`,
+		"deploy/terraform/api.yaml": terraformDoc("api"),
+	})
+
+	codegenPath := buildCLI(t)
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+
+	tests := []struct {
+		name  string
+		env   []string
+		stdin string
+		args  []string
+		want  string
+	}{
+		{"refused in CI", []string{"CI=true"}, "", []string{"destroy", "-e", "dev"}, "refusing to destroy dev from CI, pass --allow-ci"},
+		{"protected needs the name typed", nil, "yes\n", []string{"destroy", "-e", "prod"}, "confirmation did not match prod, nothing was destroyed"},
+		{"confirm must match", []string{"CI=true"}, "", []string{"destroy", "-e", "prod", "--allow-ci", "--confirm", "dev"}, "--confirm dev does not match environment prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := cliCommand(codegenPath, tempDir, tt.env, tt.args...)
+			cmd.Stdin = strings.NewReader(tt.stdin)
+			out, err := cmd.CombinedOutput()
+			if err == nil {
+				t.Fatalf("Expected destroy to be refused, got: %s", out)
+			}
+			if !strings.Contains(string(out), tt.want) {
+				t.Errorf("Expected %q, got: %s", tt.want, out)
+			}
+		})
+	}
+}
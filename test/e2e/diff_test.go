@@ -0,0 +1,107 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dknathalage/dkn/pkg/output"
+)
+
+func TestCLI_DiffAndDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+	})
+
+	codegenPath := buildCLI(t)
+
+	out, err := runCLI(t, codegenPath, tempDir, nil, "gen", "--dry-run")
+	if err != nil {
+		t.Fatalf("Dry run failed: %v\nOutput: %s", err, out)
+	}
+	if !strings.Contains(out, "create terraform/api/main.tf") {
+		t.Errorf("Expected the dry run to list new files, got: %s", out)
+	}
+	if strings.Contains(out, "Generated Terraform configuration") {
+		t.Errorf("Expected the dry run not to claim it generated anything, got: %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "terraform")); !os.IsNotExist(err) {
+		t.Error("Dry run should not write anything")
+	}
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "diff", "--check"); err != nil || !strings.Contains(out, "up to date") {
+		t.Fatalf("Expected generated files to be up to date: %v\nOutput: %s", err, out)
+	}
+
+	writeFiles(t, tempDir, map[string]string{"deploy/environments/prod.yaml": environmentDoc("prod")})
+
+	out, err = runCLI(t, codegenPath, tempDir, nil, "diff", "--check")
+	if err == nil {
+		t.Fatalf("Expected diff --check to fail when generated files are stale, got: %s", out)
+	}
+	for _, want := range []string{
+		"--- /dev/null\n+++ b/terraform/api/tfvars/prod.tfvars\n@@ -0,0 +1,4 @@\n",
+		`+environment    = "prod"`,
+		"--- a/terraform/api/Taskfile.yaml\n+++ b/terraform/api/Taskfile.yaml\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in diff, got: %s", want, out)
+		}
+	}
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "diff"); err != nil {
+		t.Errorf("Expected diff without --check to succeed: %v\nOutput: %s", err, out)
+	}
+
+	// Hand edits are reported along with the rest of the changes.
+	taskfilePath := filepath.Join(tempDir, "terraform", "api", "Taskfile.yaml")
+	writeFiles(t, tempDir, map[string]string{"terraform/api/Taskfile.yaml": readFile(t, taskfilePath) + "# edited\n"})
+
+	out, err = runCLI(t, codegenPath, tempDir, nil, "gen", "--dry-run")
+	if err == nil {
+		t.Fatalf("Expected the dry run to fail on the edited Taskfile, got: %s", out)
+	}
+	for _, want := range []string{
+		"create terraform/api/tfvars/prod.tfvars",
+		"refusing to overwrite or remove 1 file(s)",
+		"  terraform/api/Taskfile.yaml",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in dry run output, got: %s", want, out)
+		}
+	}
+}
+
+func TestOutput_UnifiedDiff(t *testing.T) {
+	change := output.Change{
+		Path: "main.tf",
+		Old:  []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"),
+		New:  []byte("a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"),
+	}
+
+	want := `--- a/main.tf
++++ b/main.tf
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	if got := change.Diff(); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
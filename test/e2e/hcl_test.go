@@ -0,0 +1,60 @@
+package e2e
+
+import (
+	"testing"
+
+	"github.com/dknathalage/dkn/pkg/hcl"
+)
+
+func TestHCL_EscapesLiteralStrings(t *testing.T) {
+	file := hcl.NewFile()
+	file.Body.SetAttribute("literal", hcl.String("${var.x} %{if} \"q\"\nline"))
+	file.Body.SetAttribute("template", hcl.Template("${var.x}"))
+
+	got := string(file.Bytes())
+	for _, want := range []string{
+		`literal  = "$${var.x} %%{if} \"q\"\nline"`,
+		`template = "${var.x}"`,
+	} {
+		if !contains(got, want) {
+			t.Errorf("Expected %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestHCL_Layout(t *testing.T) {
+	file := hcl.NewFile("autogenerated")
+	block := file.Body.AppendBlock("resource", "google_storage_bucket", "assets")
+	block.Body.SetAttributes(map[string]hcl.Value{
+		"name":     hcl.String("assets"),
+		"location": hcl.String("AU"),
+		"labels":   hcl.Object(map[string]hcl.Value{"team": hcl.String("web")}),
+		"tags":     hcl.List(hcl.String("a")),
+		"website":  hcl.Object(map[string]hcl.Value{"main_page_suffix": hcl.String("index.html")}),
+	})
+	block.Body.AppendBlock("versioning").Body.SetAttribute("enabled", hcl.Bool(true))
+	file.Body.AppendBlock("terraform")
+
+	want := `# autogenerated
+resource "google_storage_bucket" "assets" {
+  labels = {
+    team = "web"
+  }
+  location = "AU"
+  name     = "assets"
+  tags     = ["a"]
+  website = {
+    main_page_suffix = "index.html"
+  }
+
+  versioning {
+    enabled = true
+  }
+}
+
+terraform {}
+`
+	if got := string(file.Bytes()); got != want {
+		t.Errorf("Unexpected HCL output:\n%s\nwant:\n%s", got, want)
+	}
+}
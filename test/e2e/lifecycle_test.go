@@ -0,0 +1,423 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCLI_Lifecycle(t *testing.T) {
+	codegenPath := buildCLI(t)
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		fail    string
+		steps   [][]string
+		wantErr string
+		// wantOutput is expected in the output of the last step.
+		wantOutput []string
+		want       []string
+	}{
+		{
+			name:  "apply plans and applies in dependency order",
+			steps: [][]string{{"apply", "-e", "dev", "--auto-approve"}},
+			want: []string{
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/dev.tfstate",
+				"network plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"network show -json .terraform/dev.tfplan",
+				"network apply .terraform/dev.tfplan",
+				"app init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/app/dev.tfstate",
+				"app plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"app show -json .terraform/dev.tfplan",
+				"app apply .terraform/dev.tfplan",
+			},
+		},
+		{
+			name:  "plan summarises each component",
+			steps: [][]string{{"plan", "-e", "dev"}},
+			wantOutput: []string{
+				"Plan summary for dev environment:\n  network: 1 to add, 1 to change, 0 to destroy\n  app: 1 to add, 1 to change, 0 to destroy\n",
+			},
+			want: []string{
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/dev.tfstate",
+				"network plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"network show -json .terraform/dev.tfplan",
+				"app init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/app/dev.tfstate",
+				"app plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"app show -json .terraform/dev.tfplan",
+			},
+		},
+		{
+			name:  "apply uses the saved plans",
+			steps: [][]string{{"plan", "-e", "dev"}, {"apply", "-e", "dev", "--use-plan", "--auto-approve"}},
+			want: []string{
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/dev.tfstate",
+				"network plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"network show -json .terraform/dev.tfplan",
+				"app init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/app/dev.tfstate",
+				"app plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"app show -json .terraform/dev.tfplan",
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/dev.tfstate",
+				"network show -json .terraform/dev.tfplan",
+				"network apply .terraform/dev.tfplan",
+				"app init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/app/dev.tfstate",
+				"app show -json .terraform/dev.tfplan",
+				"app apply .terraform/dev.tfplan",
+			},
+		},
+		{
+			name:    "a failed apply stops dependents",
+			fail:    "network apply",
+			steps:   [][]string{{"apply", "-e", "dev", "--auto-approve"}},
+			wantErr: "terraform apply failed for network: terraform apply exited with code 3",
+			want: []string{
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/dev.tfstate",
+				"network plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"network show -json .terraform/dev.tfplan",
+				"network apply .terraform/dev.tfplan",
+			},
+		},
+		{
+			name: "the workspace strategy selects the environment's workspace",
+			files: map[string]string{
+				"deploy/terraform/network.yaml": "kind: Terraform\nmetadata:\n  name: network\nspec:\n  stateStrategy: workspace\n",
+			},
+			steps: [][]string{{"plan", "-e", "dev"}},
+			want: []string{
+				"network init -backend-config=path=.terraform-state/test-org/test-repo/network.tfstate",
+				"network workspace select -or-create dev",
+				"network plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"network show -json .terraform/dev.tfplan",
+				"app init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/app/dev.tfstate",
+				"app plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"app show -json .terraform/dev.tfplan",
+			},
+		},
+		{
+			name: "environments keep their own state without a backend",
+			files: map[string]string{
+				"deploy/environments/prod.yaml": environmentDoc("prod"),
+			},
+			steps: [][]string{{"plan", "-e", "dev", "-n", "network"}, {"plan", "-e", "prod", "-n", "network"}},
+			want: []string{
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/dev.tfstate",
+				"network plan -var-file=tfvars/dev.tfvars -out=.terraform/dev.tfplan",
+				"network show -json .terraform/dev.tfplan",
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/prod.tfstate",
+				"network plan -var-file=tfvars/prod.tfvars -out=.terraform/prod.tfplan",
+				"network show -json .terraform/prod.tfplan",
+			},
+		},
+		{
+			name:  "destroy goes in reverse order",
+			steps: [][]string{{"destroy", "-e", "dev", "--confirm", "dev"}},
+			want: []string{
+				"app init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/app/dev.tfstate",
+				"app destroy -var-file=tfvars/dev.tfvars -auto-approve",
+				"network init -reconfigure -backend-config=path=.terraform-state/test-org/test-repo/network/dev.tfstate",
+				"network destroy -var-file=tfvars/dev.tfvars -auto-approve",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			logPath := filepath.Join(tempDir, "terraform.log")
+			writeLifecycleProject(t, tempDir)
+			writeFiles(t, tempDir, tt.files)
+
+			env := append(fakeTerraformEnv(t, logPath), "FAKE_TERRAFORM_FAIL="+tt.fail, "FAKE_TERRAFORM_EXIT=3")
+
+			if out, err := runCLI(t, codegenPath, tempDir, env, "gen"); err != nil {
+				t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+			}
+
+			var out string
+			for i, step := range tt.steps {
+				var err error
+				out, err = runCLI(t, codegenPath, tempDir, env, step...)
+				if i < len(tt.steps)-1 || tt.wantErr == "" {
+					if err != nil {
+						t.Fatalf("%s failed: %v\nOutput: %s", step[0], err, out)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(out, tt.wantErr) {
+					t.Fatalf("Expected %q, got: %v\nOutput: %s", tt.wantErr, err, out)
+				}
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out, want) {
+					t.Errorf("Expected %q in output, got: %s", want, out)
+				}
+			}
+
+			if got := readFile(t, logPath); got != strings.Join(tt.want, "\n")+"\n" {
+				t.Errorf("Unexpected terraform calls:\n%s\nwant:\n%s", got, strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCLI_LifecycleInterrupts(t *testing.T) {
+	codegenPath := buildCLI(t)
+
+	tests := []struct {
+		name string
+		args []string
+		// fake makes network apply hang until interrupted, or run until
+		// killed.
+		fake     string
+		signals  int
+		wantErr  string
+		wantCall string
+	}{
+		{"timeout", []string{"--timeout", "1s"}, "FAKE_TERRAFORM_HANG", 0, "network timed out after 1s", "network interrupted"},
+		{"sigterm", nil, "FAKE_TERRAFORM_HANG", 1, "terraform apply stopped: terminated", "network interrupted"},
+		{"kill after", []string{"--timeout", "1s", "--kill-after", "1s"}, "FAKE_TERRAFORM_STUCK", 0, "network timed out after 1s", "network ignored interrupt"},
+		{"second sigterm", nil, "FAKE_TERRAFORM_STUCK", 2, "terraform apply stopped: terminated", "network ignored interrupt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			logPath := filepath.Join(tempDir, "terraform.log")
+			writeLifecycleProject(t, tempDir)
+
+			env := append(fakeTerraformEnv(t, logPath), tt.fake+"=network apply")
+
+			var out bytes.Buffer
+			cmd := cliCommand(codegenPath, tempDir, env, append([]string{"apply", "-e", "dev", "--auto-approve"}, tt.args...)...)
+			cmd.Stdout = &out
+			cmd.Stderr = &out
+
+			if genOut, err := runCLI(t, codegenPath, tempDir, env, "gen"); err != nil {
+				t.Fatalf("CLI command failed: %v\nOutput: %s", err, genOut)
+			}
+
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			// Each signal after the first waits for the one before to
+			// reach Terraform.
+			for i, wait := range []string{"network apply", tt.wantCall}[:tt.signals] {
+				waitFor(t, func() bool {
+					data, _ := os.ReadFile(logPath)
+					return strings.Contains(string(data), wait)
+				})
+				if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+					t.Fatalf("signal %d: %v", i+1, err)
+				}
+			}
+
+			if err := cmd.Wait(); err == nil {
+				t.Fatalf("Expected apply to fail, got: %s", out.String())
+			}
+			if !strings.Contains(out.String(), tt.wantErr) {
+				t.Errorf("Expected %q, got: %s", tt.wantErr, out.String())
+			}
+
+			calls := readFile(t, logPath)
+			if !strings.Contains(calls, tt.wantCall) {
+				t.Errorf("Expected %q, got calls:\n%s", tt.wantCall, calls)
+			}
+			if strings.Contains(calls, "app ") {
+				t.Errorf("Expected queued components not to start, got calls:\n%s", calls)
+			}
+		})
+	}
+}
+
+func TestCLI_Parallelism(t *testing.T) {
+	codegenPath := buildCLI(t)
+
+	tests := []struct {
+		name       string
+		env        []string
+		wantErr    string
+		wantOutput []string
+		notCalled  string
+	}{
+		{
+			// Each apply waits for the other, so they only finish when
+			// they run at the same time.
+			name:       "independent components run at once",
+			env:        []string{"FAKE_TERRAFORM_BARRIER=apply", "FAKE_TERRAFORM_BARRIER_SIZE=2"},
+			wantOutput: []string{"[api] Apply complete!", "[web] Apply complete!", "[api] Terraform has been successfully initialized!\n"},
+		},
+		{
+			// web is still applying when api fails, so worker must not
+			// take the free slot.
+			name:      "nothing starts after a failure",
+			env:       []string{"FAKE_TERRAFORM_FAIL=api init", "FAKE_TERRAFORM_DELAY=web apply"},
+			wantErr:   "terraform init failed for api: terraform init exited with code 1",
+			notCalled: "worker ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			logPath := filepath.Join(tempDir, "terraform.log")
+			writeFiles(t, tempDir, map[string]string{
+				"deploy/environments/dev.yaml": environmentDoc("dev"),
+				"deploy/terraform/api.yaml":    terraformDoc("api"),
+				"deploy/terraform/web.yaml":    terraformDoc("web"),
+				"deploy/terraform/worker.yaml": terraformDoc("worker"),
+			})
+
+			env := append(fakeTerraformEnv(t, logPath), tt.env...)
+
+			if out, err := runCLI(t, codegenPath, tempDir, env, "gen"); err != nil {
+				t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+			}
+
+			out, err := runCLI(t, codegenPath, tempDir, env, "apply", "-e", "dev", "--auto-approve", "--parallelism", "2")
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("apply failed: %v\nOutput: %s", err, out)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(out, tt.wantErr)) {
+				t.Fatalf("Expected %q, got: %v\nOutput: %s", tt.wantErr, err, out)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out, want) {
+					t.Errorf("Expected %q in output, got: %s", want, out)
+				}
+			}
+			if calls := readFile(t, logPath); tt.notCalled != "" && strings.Contains(calls, tt.notCalled) {
+				t.Errorf("Expected no %q calls, got:\n%s", tt.notCalled, calls)
+			}
+		})
+	}
+}
+
+func TestCLI_DuplicateComponents(t *testing.T) {
+	codegenPath := buildCLI(t)
+
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "terraform.log")
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/app.yaml":    terraformDoc("app"),
+		"deploy/terraform/copy.yaml":   terraformDoc("app"),
+	})
+
+	for _, args := range [][]string{{"plan", "-e", "dev"}, {"clean"}} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		cmd := exec.CommandContext(ctx, codegenPath, args...)
+		cmd.Dir = tempDir
+		cmd.Env = append(os.Environ(), fakeTerraformEnv(t, logPath)...)
+		out, err := cmd.CombinedOutput()
+		timedOut := ctx.Err() != nil
+		cancel()
+
+		if timedOut {
+			t.Fatalf("%s did not finish", args[0])
+		}
+		want := "duplicate component app in deploy/terraform/copy.yaml, first defined in deploy/terraform/app.yaml"
+		if err == nil || !strings.Contains(string(out), want) {
+			t.Errorf("Expected %s to fail with %q, got: %v\nOutput: %s", args[0], want, err, out)
+		}
+	}
+
+	output, err := runValidate(t, tempDir)
+	if err == nil || !strings.Contains(output, `deploy/terraform/copy.yaml:3:9: duplicate Terraform "app"`) {
+		t.Errorf("Expected validate to report the duplicate, got: %v\nOutput: %s", err, output)
+	}
+}
+
+func TestCLI_RequiredVersion(t *testing.T) {
+	codegenPath := buildCLI(t)
+
+	tempDir := t.TempDir()
+	writeLifecycleProject(t, tempDir)
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml": `kind: Project
+metadata:
+  name: test-project
+spec:
+  binary: tofu
+  requiredVersion: "~> 1.6.0"
+`,
+	})
+
+	// Only a tofu binary is put on PATH, so nothing can fall back to terraform.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	binDir := t.TempDir()
+	if err := os.Symlink(filepath.Join(wd, "testdata", "fake-terraform", "terraform"), filepath.Join(binDir, "tofu")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "PATH=" + binDir + string(os.PathListSeparator) + os.Getenv("PATH")
+
+	if out, err := runCLI(t, codegenPath, tempDir, []string{path}, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+
+	out, err := runCLI(t, codegenPath, tempDir, []string{path, "FAKE_TERRAFORM_VERSION=1.7.1"}, "plan", "-e", "dev")
+	if err == nil || !strings.Contains(out, "tofu 1.7.1 is installed but the project requires ~> 1.6.0") {
+		t.Errorf("Expected plan to fail on the version mismatch, got: %v\nOutput: %s", err, out)
+	}
+	if strings.Contains(out, "Initializing") {
+		t.Errorf("Expected the version to be checked before anything runs, got: %s", out)
+	}
+
+	if out, err := runCLI(t, codegenPath, tempDir, []string{path, "FAKE_TERRAFORM_VERSION=1.6.2"}, "plan", "-e", "dev"); err != nil {
+		t.Errorf("Expected plan to run with a matching version: %v\nOutput: %s", err, out)
+	}
+}
+
+// writeLifecycleProject writes a dev environment with two components, app
+// depending on network.
+func writeLifecycleProject(t *testing.T, root string) {
+	t.Helper()
+
+	writeFiles(t, root, map[string]string{
+		"deploy/environments/dev.yaml":  environmentDoc("dev"),
+		"deploy/terraform/network.yaml": terraformDoc("network"),
+		"deploy/terraform/app.yaml": `kind: Terraform
+metadata:
+  name: app
+spec:
+  dependsOn:
+    - network
+`,
+	})
+}
+
+// fakeTerraformEnv returns the variables that put the fake terraform from
+// testdata first in PATH and log its calls to logPath.
+func fakeTerraformEnv(t *testing.T, logPath string) []string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	fakeBin := filepath.Join(wd, "testdata", "fake-terraform")
+
+	return []string{
+		"GO_TEST_MODE=1",
+		"CI=",
+		"PATH=" + fakeBin + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"FAKE_TERRAFORM_LOG=" + logPath,
+	}
+}
+
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(10 * time.Second); !done(); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting")
+		}
+	}
+}
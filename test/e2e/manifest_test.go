@@ -0,0 +1,122 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dknathalage/dkn/pkg/output"
+)
+
+func TestCLI_RefusesToOverwriteEditedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          projectDoc,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+	})
+
+	codegenPath := buildCLI(t)
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, output.ManifestPath)); err != nil {
+		t.Fatalf("Expected a manifest to be written: %v", err)
+	}
+
+	providerPath := filepath.Join(tempDir, "terraform", "api", "provider.tf")
+	edited := readFile(t, providerPath) + "# tuned by hand\n"
+	writeFiles(t, tempDir, map[string]string{"terraform/api/provider.tf": edited})
+
+	out, err := runCLI(t, codegenPath, tempDir, nil, "gen")
+	if err == nil {
+		t.Fatalf("Expected regeneration to fail on an edited file, got: %s", out)
+	}
+	if !strings.Contains(out, "terraform/api/provider.tf") || !strings.Contains(out, "--force") {
+		t.Errorf("Expected the edited file and --force to be reported, got: %s", out)
+	}
+	if readFile(t, providerPath) != edited {
+		t.Error("Edited provider.tf should be left alone")
+	}
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "gen", "--force"); err != nil {
+		t.Fatalf("CLI command with --force failed: %v\nOutput: %s", err, out)
+	}
+	if contains(readFile(t, providerPath), "tuned by hand") {
+		t.Error("Expected --force to overwrite provider.tf")
+	}
+}
+
+func TestTerraformPlugin_PreservesUserRegions(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+	})
+	generate(t, tempDir)
+
+	mainPath := filepath.Join(tempDir, "terraform", "api", "main.tf")
+	custom := `resource "null_resource" "custom" {}` + "\n"
+	edited := strings.Replace(readFile(t, mainPath), "# dkn:user-end main", custom+"# dkn:user-end main", 1)
+	writeFiles(t, tempDir, map[string]string{"terraform/api/main.tf": edited})
+
+	// Regenerating must not treat the region as an edit.
+	generate(t, tempDir)
+
+	if !contains(readFile(t, mainPath), custom) {
+		t.Errorf("Expected the user region to survive regeneration:\n%s", readFile(t, mainPath))
+	}
+}
+
+func TestTerraformPlugin_MergesTfvars(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	component := `kind: Terraform
+metadata:
+  name: api
+spec:
+  variables:
+    - name: machine_type
+      type: string
+      values:
+        dev: e2-small
+    - name: replicas
+      type: number
+      values:
+        dev: 1
+`
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    component,
+	})
+	generate(t, tempDir)
+
+	tfvarsPath := filepath.Join(tempDir, "terraform", "api", "tfvars", "dev.tfvars")
+	edited := readFile(t, tfvarsPath) + "extra_tags = {\n  team = \"web\"\n}\n"
+	writeFiles(t, tempDir, map[string]string{"terraform/api/tfvars/dev.tfvars": edited})
+
+	// machine_type changes, replicas is no longer declared.
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/terraform/api.yaml": strings.Replace(component[:strings.Index(component, "    - name: replicas")], "e2-small", "e2-medium", 1),
+	})
+	generate(t, tempDir)
+
+	tfvars := readHCL(t, tfvarsPath)
+	if !contains(tfvars, `machine_type = "e2-medium"`) {
+		t.Errorf("Expected the owned key to be updated:\n%s", tfvars)
+	}
+	if contains(tfvars, "replicas") {
+		t.Errorf("Expected the key dkn no longer generates to be dropped:\n%s", tfvars)
+	}
+	if !contains(tfvars, `extra_tags = { team = "web" }`) {
+		t.Errorf("Expected the user-added key to be kept:\n%s", tfvars)
+	}
+}
@@ -0,0 +1,113 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dknathalage/dkn/pkg/output"
+)
+
+func TestCLI_Prune(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml":  environmentDoc("dev"),
+		"deploy/environments/prod.yaml": environmentDoc("prod"),
+		"deploy/terraform/api.yaml":     terraformDoc("api"),
+		"deploy/terraform/web.yaml":     terraformDoc("web"),
+		"deploy/terraform/worker.yaml":  terraformDoc("worker"),
+	})
+
+	codegenPath := buildCLI(t)
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+
+	// web was initialised, worker kept workspace state and api kept local
+	// backend state, so their state may outlive the config.
+	for _, dir := range []string{"web/.terraform", "worker/terraform.tfstate.d", "api/.terraform-state"} {
+		if err := os.MkdirAll(filepath.Join(tempDir, "terraform", dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"web.yaml", "worker.yaml"} {
+		if err := os.Remove(filepath.Join(tempDir, "deploy", "terraform", name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(tempDir, "deploy", "environments", "prod.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "terraform", "web", "main.tf")); err != nil {
+		t.Error("Expected gen without --prune to leave orphaned files")
+	}
+
+	out, err := runCLI(t, codegenPath, tempDir, nil, "gen", "--prune")
+	if err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+	for _, want := range []string{
+		"Terraform state may still exist for web;",
+		"Terraform state may still exist for worker;",
+		"Terraform state may still exist for api in prod;",
+	} {
+		if strings.Count(out, want) != 1 {
+			t.Errorf("Expected one %q warning, got: %s", want, out)
+		}
+	}
+
+	for _, name := range []string{"terraform/web/main.tf", "terraform/web/tfvars", "terraform/api/tfvars/prod.tfvars"} {
+		if _, err := os.Stat(filepath.Join(tempDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be pruned", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "terraform", "api", "tfvars", "dev.tfvars")); err != nil {
+		t.Error("Expected dev.tfvars to be kept")
+	}
+
+	manifest, err := output.LoadManifest(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	for name := range manifest.Files {
+		if strings.HasPrefix(name, "terraform/web/") || strings.HasSuffix(name, "prod.tfvars") {
+			t.Errorf("Expected %s to be dropped from the manifest", name)
+		}
+	}
+}
+
+func TestCLI_CleanPrunesOrphans(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+		"deploy/terraform/web.yaml":    terraformDoc("web"),
+	})
+
+	codegenPath := buildCLI(t)
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "gen"); err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, out)
+	}
+	if err := os.Remove(filepath.Join(tempDir, "deploy", "terraform", "web.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := runCLI(t, codegenPath, tempDir, nil, "clean"); err != nil {
+		t.Fatalf("Clean failed: %v\nOutput: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "terraform", "web")); !os.IsNotExist(err) {
+		t.Error("Expected the orphaned component directory to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "terraform", "api", "main.tf")); err != nil {
+		t.Error("Expected configured components to be kept")
+	}
+}
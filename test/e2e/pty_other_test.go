@@ -0,0 +1,14 @@
+//go:build !linux
+
+package e2e
+
+import (
+	"os"
+	"testing"
+)
+
+// openPTY skips the test where opening a pseudo-terminal is not supported.
+func openPTY(t *testing.T) (*os.File, *os.File) {
+	t.Skip("pseudo-terminals are only opened on linux")
+	return nil, nil
+}
@@ -0,0 +1,60 @@
+package e2e
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dknathalage/dkn/pkg/config"
+	"github.com/dknathalage/dkn/pkg/plugins/terraform"
+)
+
+func TestSchema_DerivedFromTypes(t *testing.T) {
+	kinds := terraform.New().KindTypes()
+	schema := config.Schema("Terraform", kinds["Terraform"])
+
+	properties := schema["properties"].(map[string]interface{})
+	if kind := properties["kind"].(map[string]interface{}); kind["const"] != "Terraform" {
+		t.Errorf("Expected kind to be pinned to Terraform, got %v", kind["const"])
+	}
+
+	spec := properties["spec"].(map[string]interface{})
+	specProperties := spec["properties"].(map[string]interface{})
+	refs, ok := specProperties["environmentRefs"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected spec.environmentRefs in schema, got %v", specProperties)
+	}
+	if refs["type"] != "array" {
+		t.Errorf("Expected spec.environmentRefs to be an array, got %v", refs["type"])
+	}
+
+	if spec["additionalProperties"] != false {
+		t.Error("Expected spec to reject unknown fields")
+	}
+}
+
+func TestCLI_SchemaOut(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cmd := exec.Command(buildCLI(t), "schema", "--out", "schemas")
+	cmd.Dir = tempDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, output)
+	}
+
+	for _, name := range []string{"dkn.schema.json", "environment.schema.json", "terraform.schema.json"} {
+		data, err := os.ReadFile(filepath.Join(tempDir, "schemas", name))
+		if err != nil {
+			t.Errorf("Expected schema %s to be written: %v", name, err)
+			continue
+		}
+
+		var schema map[string]interface{}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			t.Errorf("Schema %s is not valid JSON: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package e2e
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestTerraformPlugin_Taskfiles(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          projectDoc,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/environments/prod.yaml": `kind: Environment
+metadata:
+  name: prod
+spec:
+  backend:
+    config:
+      bucket: prod-state
+`,
+		"deploy/terraform/api.yaml": terraformDoc("api"),
+		"deploy/terraform/db.yaml":  terraformDoc("db"),
+	})
+
+	generate(t, tempDir)
+
+	var component struct {
+		Tasks map[string]struct {
+			Cmds []interface{} `yaml:"cmds"`
+		} `yaml:"tasks"`
+	}
+	parseYAML(t, filepath.Join(tempDir, "terraform", "api", "Taskfile.yaml"), &component)
+
+	for _, name := range []string{"tf:api:init", "tf:api:plan", "tf:api:apply", "tf:api:destroy"} {
+		if _, ok := component.Tasks[name]; !ok {
+			t.Errorf("Expected task %s in component Taskfile", name)
+		}
+	}
+
+	initCmd, _ := component.Tasks["tf:api:init"].Cmds[0].(string)
+	for _, want := range []string{
+		`{{if eq .ENV "dev"}}terraform init -reconfigure -backend-config=prefix=test-org/test-repo/api/dev`,
+		`{{else if eq .ENV "prod"}}terraform init -reconfigure -backend-config=prefix=test-org/test-repo/api/prod -backend-config=bucket=prod-state{{end}}`,
+	} {
+		if !contains(initCmd, want) {
+			t.Errorf("Expected %q in init command, got %s", want, initCmd)
+		}
+	}
+
+	var root struct {
+		Includes map[string]struct {
+			Taskfile string `yaml:"taskfile"`
+			Flatten  bool   `yaml:"flatten"`
+		} `yaml:"includes"`
+	}
+	parseYAML(t, filepath.Join(tempDir, "terraform", "Taskfile.yaml"), &root)
+
+	for _, name := range []string{"api", "db"} {
+		include, ok := root.Includes[name]
+		if !ok {
+			t.Errorf("Expected root Taskfile to include %s", name)
+			continue
+		}
+		if include.Taskfile != "./"+name+"/Taskfile.yaml" || !include.Flatten {
+			t.Errorf("Unexpected include for %s: %+v", name, include)
+		}
+	}
+}
+
+func parseYAML(t *testing.T, path string, v interface{}) {
+	t.Helper()
+
+	if err := yaml.Unmarshal([]byte(readFile(t, path)), v); err != nil {
+		t.Fatalf("Failed to parse %s: %v", path, err)
+	}
+}
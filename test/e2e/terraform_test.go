@@ -4,41 +4,30 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/dknathalage/dkn/pkg/config"
+	"github.com/dknathalage/dkn/pkg/output"
 	"github.com/dknathalage/dkn/pkg/plugins/terraform"
 )
 
 func TestTerraformPlugin_Generate(t *testing.T) {
 	// Set test mode environment variable
 	t.Setenv("GO_TEST_MODE", "1")
-	
-	tempDir := t.TempDir()
-
-	configContent := `components:
-  - webapi
-  - database
-environments:
-  - dev
-  - staging
-  - prod`
 
-	terraformDir := filepath.Join(tempDir, "terraform")
-	if err := os.MkdirAll(terraformDir, 0755); err != nil {
-		t.Fatalf("Failed to create terraform directory: %v", err)
-	}
-	configPath := filepath.Join(terraformDir, "terraform.yaml")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		t.Fatalf("Failed to write test config: %v", err)
-	}
+	tempDir := t.TempDir()
 
-	plugin := terraform.New()
-	ctx := context.Background()
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":              projectDoc,
+		"deploy/environments/dev.yaml":     environmentDoc("dev"),
+		"deploy/environments/staging.yaml": environmentDoc("staging"),
+		"deploy/environments/prod.yaml":    environmentDoc("prod"),
+		"deploy/terraform/webapi.yaml":     terraformDoc("webapi"),
+		"deploy/terraform/database.yaml":   terraformDoc("database"),
+	})
 
-	err := plugin.Generate(ctx, configPath, tempDir)
-	if err != nil {
-		t.Fatalf("Failed to generate terraform config: %v", err)
-	}
+	generate(t, tempDir)
 
 	terraformOutputDir := filepath.Join(tempDir, "terraform")
 
@@ -46,6 +35,7 @@ environments:
 		componentDir := filepath.Join(terraformOutputDir, component)
 
 		expectedFiles := []string{
+			"main.tf",
 			"variables.tf",
 			"provider.tf",
 			"backend.tf",
@@ -73,30 +63,15 @@ environments:
 func TestTerraformPlugin_GeneratedContent(t *testing.T) {
 	// Set test mode environment variable
 	t.Setenv("GO_TEST_MODE", "1")
-	
+
 	tempDir := t.TempDir()
 
-	configContent := `components:
-  - testcomp
-environments:
-  - testenv`
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/testenv.yaml": environmentDoc("testenv"),
+		"deploy/terraform/testcomp.yaml":   terraformDoc("testcomp"),
+	})
 
-	terraformDir := filepath.Join(tempDir, "terraform")
-	if err := os.MkdirAll(terraformDir, 0755); err != nil {
-		t.Fatalf("Failed to create terraform directory: %v", err)
-	}
-	configPath := filepath.Join(terraformDir, "terraform.yaml")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		t.Fatalf("Failed to write test config: %v", err)
-	}
-
-	plugin := terraform.New()
-	ctx := context.Background()
-
-	err := plugin.Generate(ctx, configPath, tempDir)
-	if err != nil {
-		t.Fatalf("Failed to generate terraform config: %v", err)
-	}
+	generate(t, tempDir)
 
 	componentDir := filepath.Join(tempDir, "terraform", "testcomp")
 
@@ -137,6 +112,671 @@ environments:
 	if !contains(string(tfvarsContent), "testenv") {
 		t.Error("tfvars should contain environment name")
 	}
+
+	gitignore := readFile(t, filepath.Join(componentDir, ".gitignore"))
+	for _, want := range []string{"*.tfstate\n", "*.tfstate.*\n", "terraform.tfstate.d/\n"} {
+		if !contains(gitignore, want) {
+			t.Errorf("Expected .gitignore to ignore %q, got:\n%s", strings.TrimSpace(want), gitignore)
+		}
+	}
+}
+
+func TestTerraformPlugin_ProjectDefaults(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          projectDoc,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+		"deploy/terraform/db.yaml": `kind: Terraform
+metadata:
+  name: db
+spec:
+  backend:
+    type: s3
+    config:
+      bucket: db-state
+      region: ap-southeast-2
+  providers:
+    - name: aws
+      source: hashicorp/aws
+      version: 5.0.0
+`,
+	})
+
+	generate(t, tempDir)
+
+	apiBackend := readFile(t, filepath.Join(tempDir, "terraform", "api", "backend.tf"))
+	if !contains(apiBackend, `backend "gcs"`) || !contains(apiBackend, "test-state") {
+		t.Errorf("Expected api to use the project backend, got:\n%s", apiBackend)
+	}
+
+	apiProvider := readFile(t, filepath.Join(tempDir, "terraform", "api", "provider.tf"))
+	if !contains(apiProvider, "hashicorp/google") {
+		t.Errorf("Expected api to use the project providers, got:\n%s", apiProvider)
+	}
+
+	dbBackend := readFile(t, filepath.Join(tempDir, "terraform", "db", "backend.tf"))
+	if !contains(dbBackend, `backend "s3"`) || !contains(dbBackend, "db-state") {
+		t.Errorf("Expected db to override the backend, got:\n%s", dbBackend)
+	}
+
+	dbProvider := readFile(t, filepath.Join(tempDir, "terraform", "db", "provider.tf"))
+	if !contains(dbProvider, "hashicorp/aws") || contains(dbProvider, "hashicorp/google") {
+		t.Errorf("Expected db to override the providers, got:\n%s", dbProvider)
+	}
+}
+
+func TestTerraformPlugin_BinaryAndRequiredVersion(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml": `kind: Project
+metadata:
+  name: test-project
+spec:
+  binary: tofu
+  requiredVersion: ">= 1.6, < 2.0"
+`,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+	})
+
+	generate(t, tempDir)
+
+	provider := readHCL(t, filepath.Join(tempDir, "terraform", "api", "provider.tf"))
+	if !contains(provider, `terraform { required_version = ">= 1.6, < 2.0" required_providers {`) {
+		t.Errorf("Expected required_version in provider.tf, got:\n%s", provider)
+	}
+
+	taskfile := readFile(t, filepath.Join(tempDir, "terraform", "api", "Taskfile.yaml"))
+	if !contains(taskfile, "tofu plan") || contains(taskfile, "terraform plan") {
+		t.Errorf("Expected the Taskfile to run tofu, got:\n%s", taskfile)
+	}
+}
+
+func TestTerraformPlugin_StateBackends(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	backendDoc := func(name, config string) string {
+		return "kind: Terraform\nmetadata:\n  name: " + name + "\nspec:\n  backend:\n    type: " + name + "\n    config:\n" + config
+	}
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/s3.yaml":     backendDoc("s3", "      bucket: state\n      region: ap-southeast-2\n"),
+		"deploy/terraform/local.yaml":  backendDoc("local", "      path: /var/state\n"),
+		"deploy/terraform/http.yaml":   backendDoc("http", "      address: https://state.example.com/\n      lock_address: https://state.example.com/lock\n      lock_method: PUT\n"),
+		"deploy/terraform/remote.yaml": backendDoc("remote", "      organization: acme\n"),
+		"deploy/terraform/cloud.yaml":  backendDoc("cloud", "      organization: acme\n"),
+		"deploy/terraform/reader.yaml": `kind: Terraform
+metadata:
+  name: reader
+spec:
+  remoteStates:
+    - component: s3
+    - component: local
+    - component: remote
+`,
+	})
+
+	generate(t, tempDir)
+
+	tests := []struct {
+		component string
+		backend   []string
+		init      string
+	}{
+		{"s3", []string{`backend "s3" { bucket = "state" region = "ap-southeast-2" }`}, "-backend-config=key=test-org/test-repo/s3/dev/terraform.tfstate"},
+		{"local", []string{`backend "local" {}`}, "-backend-config=path=/var/state/test-org/test-repo/local/dev.tfstate"},
+		{"http", []string{`backend "http" { lock_method = "PUT" }`}, "-backend-config=address=https://state.example.com/test-org/test-repo/http/dev -backend-config=lock_address=https://state.example.com/lock/test-org/test-repo/http/dev"},
+		{"remote", []string{`backend "remote" { organization = "acme" workspaces { prefix = "test-org-test-repo-remote-" } }`}, "TF_WORKSPACE: '{{.ENV}}'"},
+		{"cloud", []string{`cloud { organization = "acme" workspaces { tags = ["test-org-test-repo-cloud"] } }`}, "TF_WORKSPACE: test-org-test-repo-cloud-{{.ENV}}"},
+	}
+	for _, tt := range tests {
+		componentDir := filepath.Join(tempDir, "terraform", tt.component)
+		backend := readHCL(t, filepath.Join(componentDir, "backend.tf"))
+		for _, want := range tt.backend {
+			if !contains(backend, want) {
+				t.Errorf("Expected %q in %s backend.tf, got:\n%s", want, tt.component, backend)
+			}
+		}
+		if taskfile := readFile(t, filepath.Join(componentDir, "Taskfile.yaml")); !contains(taskfile, tt.init) {
+			t.Errorf("Expected %q in %s Taskfile, got:\n%s", tt.init, tt.component, taskfile)
+		}
+	}
+
+	main := readHCL(t, filepath.Join(tempDir, "terraform", "reader", "main.tf"))
+	for _, want := range []string{
+		`key = "test-org/test-repo/s3/${var.environment}/terraform.tfstate"`,
+		`path = "/var/state/test-org/test-repo/local/${var.environment}.tfstate"`,
+		`data "terraform_remote_state" "remote" { backend = "remote" config = { organization = "acme" workspaces = { name = "test-org-test-repo-remote-${var.environment}" } } }`,
+	} {
+		if !contains(main, want) {
+			t.Errorf("Expected %q in main.tf, got:\n%s", want, main)
+		}
+	}
+}
+
+func TestTerraformPlugin_WorkspaceStrategy(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          projectDoc,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/network.yaml": `kind: Terraform
+metadata:
+  name: network
+spec:
+  stateStrategy: workspace
+`,
+		"deploy/terraform/app.yaml": `kind: Terraform
+metadata:
+  name: app
+spec:
+  remoteStates:
+    - component: network
+`,
+	})
+
+	generate(t, tempDir)
+
+	taskfile := readFile(t, filepath.Join(tempDir, "terraform", "network", "Taskfile.yaml"))
+	for _, want := range []string{
+		"terraform init -backend-config=prefix=test-org/test-repo/network",
+		"terraform workspace select -or-create {{.ENV}}",
+	} {
+		if !contains(taskfile, want) {
+			t.Errorf("Expected %q in network Taskfile, got:\n%s", want, taskfile)
+		}
+	}
+	if contains(taskfile, "-reconfigure") {
+		t.Errorf("Expected every environment to share one backend configuration, got:\n%s", taskfile)
+	}
+
+	main := readHCL(t, filepath.Join(tempDir, "terraform", "app", "main.tf"))
+	for _, want := range []string{
+		`config = { bucket = "test-state" prefix = "test-org/test-repo/network" }`,
+		`workspace = var.environment`,
+	} {
+		if !contains(main, want) {
+			t.Errorf("Expected %q in app main.tf, got:\n%s", want, main)
+		}
+	}
+}
+
+func TestTerraformPlugin_NoBakedInDefaults(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+		"deploy/terraform/web.yaml": `kind: Terraform
+metadata:
+  name: web
+spec:
+  remoteStates:
+    - component: api
+`,
+	})
+
+	generate(t, tempDir)
+
+	componentDir := filepath.Join(tempDir, "terraform", "api")
+	if backend := readHCL(t, filepath.Join(componentDir, "backend.tf")); !contains(backend, `terraform { backend "local" {} }`) {
+		t.Errorf("Expected the local backend without a configured backend, got:\n%s", backend)
+	}
+
+	provider := readHCL(t, filepath.Join(componentDir, "provider.tf"))
+	if contains(provider, "google") {
+		t.Errorf("Expected no default providers, got:\n%s", provider)
+	}
+
+	main := readHCL(t, filepath.Join(tempDir, "terraform", "web", "main.tf"))
+	want := `backend = "local" config = { path = "../api/.terraform-state/test-org/test-repo/api/${var.environment}.tfstate" }`
+	if !contains(main, want) {
+		t.Errorf("Expected %q in web main.tf, got:\n%s", want, main)
+	}
+}
+
+func TestTerraformPlugin_EnvironmentProviders(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml": projectDoc,
+		"deploy/environments/dev.yaml": `kind: Environment
+metadata:
+  name: dev
+spec:
+  providers:
+    google:
+      project: acme-dev
+      region: australia-southeast1
+`,
+		"deploy/environments/prod.yaml": `kind: Environment
+metadata:
+  name: prod
+spec:
+  backend:
+    config:
+      bucket: acme-prod-state
+  providers:
+    google:
+      project: acme-prod
+`,
+		"deploy/terraform/api.yaml": terraformDoc("api"),
+	})
+
+	generate(t, tempDir)
+
+	componentDir := filepath.Join(tempDir, "terraform", "api")
+
+	provider := readHCL(t, filepath.Join(componentDir, "provider.tf"))
+	for _, want := range []string{`provider "google"`, "project = var.google_project", "region = var.google_region"} {
+		if !contains(provider, want) {
+			t.Errorf("Expected %q in provider.tf:\n%s", want, provider)
+		}
+	}
+
+	variables := readHCL(t, filepath.Join(componentDir, "variables.tf"))
+	if !contains(variables, `variable "google_project"`) || !contains(variables, `variable "google_region"`) {
+		t.Errorf("Expected provider variables in variables.tf:\n%s", variables)
+	}
+
+	dev := readHCL(t, filepath.Join(componentDir, "tfvars", "dev.tfvars"))
+	if !contains(dev, `google_project = "acme-dev"`) || !contains(dev, `google_region = "australia-southeast1"`) {
+		t.Errorf("Expected dev provider values in dev.tfvars:\n%s", dev)
+	}
+
+	prod := readHCL(t, filepath.Join(componentDir, "tfvars", "prod.tfvars"))
+	if !contains(prod, `google_project = "acme-prod"`) || contains(prod, "google_region") {
+		t.Errorf("Expected only prod provider values in prod.tfvars:\n%s", prod)
+	}
+}
+
+func TestTerraformPlugin_EnvironmentVariables(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": `kind: Environment
+metadata:
+  name: dev
+spec:
+  variables:
+    instance_count:
+      type: number
+      value: 1
+    zones:
+      type: list(string)
+      value: [a, b]
+`,
+		"deploy/terraform/api.yaml": terraformDoc("api"),
+	})
+
+	generate(t, tempDir)
+
+	componentDir := filepath.Join(tempDir, "terraform", "api")
+
+	variables := readHCL(t, filepath.Join(componentDir, "variables.tf"))
+	if !contains(variables, `variable "instance_count"`) || !contains(variables, "type = list(string)") {
+		t.Errorf("Expected environment variables in variables.tf:\n%s", variables)
+	}
+
+	tfvars := readHCL(t, filepath.Join(componentDir, "tfvars", "dev.tfvars"))
+	if !contains(tfvars, "instance_count = 1") || !contains(tfvars, `zones = ["a", "b"]`) {
+		t.Errorf("Expected environment variable values in dev.tfvars:\n%s", tfvars)
+	}
+}
+
+func TestTerraformPlugin_ComponentVariables(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":           projectDoc,
+		"deploy/environments/dev.yaml":  environmentDoc("dev"),
+		"deploy/environments/prod.yaml": environmentDoc("prod"),
+		"deploy/terraform/api.yaml": `kind: Terraform
+metadata:
+  name: api
+spec:
+  variables:
+    - name: machine_type
+      type: string
+      description: Machine type for the API
+      default: e2-small
+      validation:
+        - condition: length(var.machine_type) > 0
+          errorMessage: machine_type must not be empty
+      values:
+        prod: e2-standard-4
+    - name: db_password
+      type: string
+      sensitive: true
+`,
+	})
+
+	generate(t, tempDir)
+
+	componentDir := filepath.Join(tempDir, "terraform", "api")
+
+	variables := readHCL(t, filepath.Join(componentDir, "variables.tf"))
+	for _, want := range []string{
+		`variable "machine_type"`,
+		`default = "e2-small"`,
+		"condition = length(var.machine_type) > 0",
+		`error_message = "machine_type must not be empty"`,
+		`variable "db_password"`,
+		"sensitive = true",
+	} {
+		if !contains(variables, want) {
+			t.Errorf("Expected %q in variables.tf:\n%s", want, variables)
+		}
+	}
+
+	dev := readHCL(t, filepath.Join(componentDir, "tfvars", "dev.tfvars"))
+	if !contains(dev, `project_name = "test-project"`) {
+		t.Errorf("Expected project_name in dev.tfvars:\n%s", dev)
+	}
+	if contains(dev, "machine_type") {
+		t.Errorf("Expected dev to rely on the default machine_type:\n%s", dev)
+	}
+
+	prod := readHCL(t, filepath.Join(componentDir, "tfvars", "prod.tfvars"))
+	if !contains(prod, `machine_type = "e2-standard-4"`) {
+		t.Errorf("Expected prod machine_type in prod.tfvars:\n%s", prod)
+	}
+}
+
+func TestTerraformPlugin_MainTf(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/network.yaml": `kind: Terraform
+metadata:
+  name: network
+spec:
+  modules:
+    - name: vpc
+      source: terraform-google-modules/network/google
+      version: 9.0.0
+      inputs:
+        network_name: ${var.environment}-vpc
+        subnets:
+          - subnet_name: main
+            subnet_ip: 10.0.0.0/24
+    - name: dns
+      source: ./modules/dns
+      inputs:
+        network: ${module.vpc.network_self_link}
+  resources:
+    - type: google_storage_bucket
+      name: assets
+      attributes:
+        name: ${var.project_name}-assets
+        location: US
+      blocks:
+        - type: versioning
+          attributes:
+            enabled: true
+`,
+	})
+
+	generate(t, tempDir)
+
+	mainTf := readHCL(t, filepath.Join(tempDir, "terraform", "network", "main.tf"))
+	for _, want := range []string{
+		"# autogenerated",
+		`module "vpc" {`,
+		`source = "terraform-google-modules/network/google"`,
+		`version = "9.0.0"`,
+		`network_name = "${var.environment}-vpc"`,
+		`subnet_ip = "10.0.0.0/24"`,
+		`module "dns" {`,
+		`network = "${module.vpc.network_self_link}"`,
+		`resource "google_storage_bucket" "assets" {`,
+		"versioning {",
+		"enabled = true",
+	} {
+		if !contains(mainTf, want) {
+			t.Errorf("Expected %q in main.tf:\n%s", want, mainTf)
+		}
+	}
+}
+
+func TestTerraformPlugin_RemoteState(t *testing.T) {
+	t.Setenv("GO_TEST_MODE", "1")
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          projectDoc,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/environments/prod.yaml": `kind: Environment
+metadata:
+  name: prod
+spec:
+  backend:
+    config:
+      bucket: prod-state
+`,
+		"deploy/terraform/network.yaml": terraformDoc("network"),
+		"deploy/terraform/database.yaml": `kind: Terraform
+metadata:
+  name: database
+spec:
+  remoteStates:
+    - component: network
+  modules:
+    - name: db
+      source: ./modules/db
+      inputs:
+        vpc_id: ${data.terraform_remote_state.network.outputs.vpc_id}
+`,
+		"deploy/terraform/cache.yaml": `kind: Terraform
+metadata:
+  name: cache
+spec:
+  environments: [dev]
+  remoteStates:
+    - component: network
+`,
+	})
+
+	generate(t, tempDir)
+
+	cache := readHCL(t, filepath.Join(tempDir, "terraform", "cache", "main.tf"))
+	for _, want := range []string{
+		`data "terraform_remote_state" "network" {`,
+		`backend = "gcs"`,
+		`bucket = "test-state"`,
+		`prefix = "test-org/test-repo/network/${var.environment}"`,
+	} {
+		if !contains(cache, want) {
+			t.Errorf("Expected %q in cache main.tf:\n%s", want, cache)
+		}
+	}
+
+	// prod overrides the bucket, so the config is chosen per environment.
+	database := readHCL(t, filepath.Join(tempDir, "terraform", "database", "main.tf"))
+	for _, want := range []string{
+		`dev = { bucket = "test-state" prefix = "test-org/test-repo/network/dev" }`,
+		`prod = { bucket = "prod-state" prefix = "test-org/test-repo/network/prod" }`,
+		`}[var.environment]`,
+		`vpc_id = "${data.terraform_remote_state.network.outputs.vpc_id}"`,
+	} {
+		if !contains(database, want) {
+			t.Errorf("Expected %q in database main.tf:\n%s", want, database)
+		}
+	}
+
+	cfg, err := terraform.LoadConfig(filepath.Join(tempDir, "deploy"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	ordered, err := cfg.SelectComponents("")
+	if err != nil {
+		t.Fatalf("Failed to order components: %v", err)
+	}
+	if ordered[0].Metadata.Name != "network" {
+		t.Errorf("Expected network to be applied before the components reading its state, got %s first", ordered[0].Metadata.Name)
+	}
+}
+
+func TestTerraformConfig_EnvironmentOrderAndSelector(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/a-prod.yaml": `kind: Environment
+metadata:
+  name: prod
+  labels:
+    tier: production
+spec:
+  order: 3
+  protected: true
+`,
+		"deploy/environments/b-staging.yaml": `kind: Environment
+metadata:
+  name: staging
+  labels:
+    tier: production
+spec:
+  order: 2
+`,
+		"deploy/environments/c-dev.yaml": `kind: Environment
+metadata:
+  name: dev
+spec:
+  order: 1
+`,
+		"deploy/terraform/api.yaml": terraformDoc("api"),
+		"deploy/terraform/cdn.yaml": `kind: Terraform
+metadata:
+  name: cdn
+spec:
+  environmentSelector:
+    matchLabels:
+      tier: production
+`,
+	})
+
+	cfg, err := terraform.LoadConfig(filepath.Join(tempDir, "deploy"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	components := make(map[string]terraform.TerraformResource)
+	for _, component := range cfg.Components {
+		components[component.Metadata.Name] = component
+	}
+
+	if got := strings.Join(cfg.EnvironmentsFor(components["api"]), ","); got != "dev,staging,prod" {
+		t.Errorf("Expected environments in promotion order, got %s", got)
+	}
+
+	if got := strings.Join(cfg.EnvironmentsFor(components["cdn"]), ","); got != "staging,prod" {
+		t.Errorf("Expected selector to pick labelled environments, got %s", got)
+	}
+
+	if prod, _ := cfg.Environment("prod"); !prod.Spec.Protected {
+		t.Error("Expected prod to be protected")
+	}
+}
+
+func TestTerraformConfig_DependencyOrder(t *testing.T) {
+	tempDir := t.TempDir()
+
+	dependsOn := func(name string, deps ...string) string {
+		doc := terraformDoc(name)
+		if len(deps) > 0 {
+			doc += "spec:\n  dependsOn: [" + strings.Join(deps, ", ") + "]\n"
+		}
+		return doc
+	}
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform.yaml": strings.Join([]string{
+			dependsOn("app", "database", "network"),
+			dependsOn("database", "network"),
+			dependsOn("dns"),
+			dependsOn("network"),
+		}, "---\n"),
+	})
+
+	cfg, err := terraform.LoadConfig(filepath.Join(tempDir, "deploy"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	ordered, err := cfg.SelectComponents("")
+	if err != nil {
+		t.Fatalf("Failed to order components: %v", err)
+	}
+	var names []string
+	for _, component := range ordered {
+		names = append(names, component.Metadata.Name)
+	}
+	if got := strings.Join(names, ","); got != "dns,network,database,app" {
+		t.Errorf("Expected dependencies first, otherwise config order, got %s", got)
+	}
+}
+
+func generate(t *testing.T, root string) {
+	t.Helper()
+
+	resources, err := config.LoadDir(filepath.Join(root, "deploy"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	out, err := output.NewWriter(root, false)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	plugin := terraform.New()
+	if err := plugin.Generate(context.Background(), resources, out); err != nil {
+		t.Fatalf("Failed to generate terraform config: %v", err)
+	}
+	if err := out.Commit(); err != nil {
+		t.Fatalf("Failed to commit generated files: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+// readHCL reads a generated file with runs of spaces collapsed, so checks
+// do not depend on how attributes happen to be aligned.
+func readHCL(t *testing.T, path string) string {
+	t.Helper()
+
+	return strings.Join(strings.Fields(readFile(t, path)), " ")
 }
 
 func contains(s, substr string) bool {
@@ -0,0 +1,431 @@
+package e2e
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCLI_ValidateReportsPositions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/environments/dup.yaml": environmentDoc("dev"),
+		"deploy/terraform/db.yaml": `kind: Terraform
+metadata:
+  name: db
+  lables:
+    team: data
+spec:
+  providers: google
+`,
+		"deploy/terraform/noname.yaml": "kind: Terraform\nmetadata:\n  description: no name\n",
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/environments/dup.yaml:3:9: duplicate Environment "dev"`,
+		`deploy/terraform/db.yaml:4:3: metadata: unknown field "lables"`,
+		`deploy/terraform/db.yaml:7:14: spec.providers: expected a list`,
+		`deploy/terraform/noname.yaml:3:3: Terraform is missing metadata.name`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+}
+
+func TestCLI_ValidateDanglingEnvironmentRef(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/db.yaml": `kind: Terraform
+metadata:
+  name: db
+spec:
+  environmentRefs:
+    - dev
+    - qa
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	want := `deploy/terraform/db.yaml:7:7: component db references unknown environment "qa"`
+	if !strings.Contains(output, want) {
+		t.Errorf("Expected %q in output: %s", want, output)
+	}
+}
+
+func TestCLI_ValidateComponentWithoutEnvironments(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/terraform/db.yaml": terraformDoc("db"),
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	want := `deploy/terraform/db.yaml:3:9: component db is not deployed to any environment, declare an Environment`
+	if !strings.Contains(output, want) {
+		t.Errorf("Expected %q in output: %s", want, output)
+	}
+}
+
+func TestCLI_ValidateEnvironmentVariableTypes(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": `kind: Environment
+metadata:
+  name: dev
+spec:
+  variables:
+    replicas:
+      type: number
+      value: three
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	want := `deploy/environments/dev.yaml:8:14: variable replicas: expected a number`
+	if !strings.Contains(output, want) {
+		t.Errorf("Expected %q in output: %s", want, output)
+	}
+}
+
+func TestCLI_ValidateComponentVariables(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml": `kind: Terraform
+metadata:
+  name: api
+spec:
+  variables:
+    - name: environment
+    - name: replicas
+      type: number
+      default: one
+      values:
+        qa: 2
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/terraform/api.yaml:6:13: variable environment is provided by dkn`,
+		`deploy/terraform/api.yaml:9:16: variable replicas: expected a number`,
+		`deploy/terraform/api.yaml:11:13: variable replicas sets a value for environment "qa"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+}
+
+func TestCLI_ValidateDependencies(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/app.yaml": `kind: Terraform
+metadata:
+  name: app
+spec:
+  dependsOn: [database, cache]
+`,
+		"deploy/terraform/database.yaml": `kind: Terraform
+metadata:
+  name: database
+spec:
+  dependsOn: [app]
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/terraform/app.yaml:5:25: component app depends on unknown component "cache"`,
+		`deploy/terraform/app.yaml:5:14: dependency cycle: app -> database -> app`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+}
+
+func TestCLI_ValidateRemoteStates(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml":  environmentDoc("dev"),
+		"deploy/environments/prod.yaml": environmentDoc("prod"),
+		"deploy/terraform/network.yaml": `kind: Terraform
+metadata:
+  name: network
+spec:
+  environments: [dev]
+`,
+		"deploy/terraform/database.yaml": `kind: Terraform
+metadata:
+  name: database
+spec:
+  remoteStates:
+    - component: network
+    - component: dns
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/terraform/database.yaml:6:18: component database reads the state of network, which is not deployed to environment prod`,
+		`deploy/terraform/database.yaml:7:18: component database reads the state of unknown component "dns"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+}
+
+func TestCLI_ValidateBinary(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml": `kind: Project
+metadata:
+  name: test-project
+spec:
+  binary: terragrunt
+  requiredVersion: ">= one"
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/project.yaml:5:11: unknown binary "terragrunt", expected terraform or tofu`,
+		`deploy/project.yaml:6:20: invalid version constraint ">= one"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+}
+
+func TestCLI_ValidateBackends(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml": `kind: Project
+metadata:
+  name: test-project
+spec:
+  backend:
+    type: s3
+    config:
+      key: state.tfstate
+      bucket: state
+`,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/environments/prod.yaml": `kind: Environment
+metadata:
+  name: prod
+spec:
+  backend:
+    config:
+      region: ap-southeast-2
+`,
+		"deploy/terraform/api.yaml": terraformDoc("api"),
+		"deploy/terraform/web.yaml": terraformDoc("web"),
+		"deploy/terraform/app.yaml": `kind: Terraform
+metadata:
+  name: app
+spec:
+  backend:
+    type: consul
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/project.yaml:8:12: s3 backend: key is set by dkn from naming.statePrefix`,
+		`deploy/project.yaml:8:7: s3 backend needs region, set it here or in environment dev`,
+		`deploy/terraform/app.yaml:6:11: unsupported backend type "consul"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+	if strings.Count(output, "needs region") != 1 {
+		t.Errorf("Expected the shared project backend to be reported once: %s", output)
+	}
+}
+
+func TestCLI_ValidateStateStrategy(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          projectDoc,
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/environments/prod.yaml": `kind: Environment
+metadata:
+  name: prod
+spec:
+  backend:
+    config:
+      bucket: prod-state
+`,
+		"deploy/terraform/api.yaml": `kind: Terraform
+metadata:
+  name: api
+spec:
+  stateStrategy: workspaces
+`,
+		"deploy/terraform/app.yaml": `kind: Terraform
+metadata:
+  name: app
+spec:
+  stateStrategy: workspace
+`,
+		"deploy/terraform/web.yaml": `kind: Terraform
+metadata:
+  name: web
+spec:
+  stateStrategy: workspace
+  backend:
+    type: remote
+    config:
+      organization: acme
+`,
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/terraform/api.yaml:5:18: unknown state strategy "workspaces", expected prefix or workspace`,
+		`deploy/environments/prod.yaml:7:7: environment prod sets backend config, but component app shares one backend across environments with the workspace state strategy`,
+		`deploy/terraform/web.yaml:5:18: the remote backend already keeps each environment in its own workspace`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+}
+
+func TestCLI_ValidateStatePrefix(t *testing.T) {
+	statePrefix := func(prefix string) string {
+		return "kind: Project\nmetadata:\n  name: test-project\nspec:\n  naming:\n    statePrefix: \"" + prefix + "\"\n"
+	}
+
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          statePrefix("{org}/{repo}"),
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    terraformDoc("api"),
+		"deploy/terraform/web.yaml":    terraformDoc("web"),
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err == nil {
+		t.Fatalf("Expected validate to fail, output: %s", output)
+	}
+
+	expected := []string{
+		`deploy/project.yaml:6:18: state prefix "{org}/{repo}" has no {component}, so components would share their state`,
+		`deploy/project.yaml:6:18: state prefix "{org}/{repo}" has no {environment}, so the environments of api, web would share their state, add it or use the workspace state strategy`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output: %s", want, output)
+		}
+	}
+
+	// The workspace strategy keeps environments apart on its own.
+	tempDir = t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/project.yaml":          statePrefix("{org}/{repo}/{component}"),
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/api.yaml":    "kind: Terraform\nmetadata:\n  name: api\nspec:\n  stateStrategy: workspace\n",
+	})
+
+	if output, err := runValidate(t, tempDir); err != nil {
+		t.Errorf("Expected a prefix without {environment} to be valid with the workspace strategy: %v\nOutput: %s", err, output)
+	}
+}
+
+func TestCLI_ValidateValidConfig(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFiles(t, tempDir, map[string]string{
+		"deploy/environments/dev.yaml": environmentDoc("dev"),
+		"deploy/terraform/db.yaml":     terraformDoc("db"),
+	})
+
+	output, err := runValidate(t, tempDir)
+	if err != nil {
+		t.Fatalf("Expected validate to succeed: %v\nOutput: %s", err, output)
+	}
+
+	if !strings.Contains(output, "Configuration is valid") {
+		t.Errorf("Expected success message, got: %s", output)
+	}
+}
+
+func runValidate(t *testing.T, dir string) (string, error) {
+	t.Helper()
+
+	cmd := exec.Command(buildCLI(t), "validate")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO_TEST_MODE=1")
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}